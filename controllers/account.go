@@ -0,0 +1,262 @@
+package controllers
+
+import (
+	"database/sql"
+	"net/http"
+	"reviser/internal/audit"
+	"reviser/internal/inits"
+	"reviser/internal/tokens"
+	"reviser/middlewares"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// uniqueViolation is the Postgres error code for a unique constraint failure
+const uniqueViolation = "23505"
+
+func fetchProfile(ctx *gin.Context, username string) (gin.H, error) {
+	var name, role, email, timezone string
+	var mfaEnabled bool
+	err := inits.DB.QueryRowContext(ctx,
+		`SELECT Name, Username, Role, COALESCE(email, ''), timezone, totp_enabled
+			FROM users WHERE username = $1`,
+		username).Scan(&name, &username, &role, &email, &timezone, &mfaEnabled)
+	if err != nil {
+		return nil, err
+	}
+	return gin.H{
+		"Name":        name,
+		"Username":    username,
+		"Role":        role,
+		"Email":       email,
+		"Timezone":    timezone,
+		"Mfa_Enabled": mfaEnabled,
+	}, nil
+}
+
+// FetchProfile returns the profile of the current user
+func FetchProfile(ctx *gin.Context) {
+	user, ok := currentUser(ctx)
+	if !ok {
+		ctx.JSON(500, gin.H{"error": "User not found in context"})
+		return
+	}
+
+	profile, err := fetchProfile(ctx, user.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	ctx.JSON(200, gin.H{"user": profile})
+}
+
+// UpdateProfile changes the name, email, timezone or username of the current user.
+// A new access token is issued after a rename since the old one names
+// the previous username
+func UpdateProfile(ctx *gin.Context) {
+	user, ok := currentUser(ctx)
+	if !ok {
+		ctx.JSON(500, gin.H{"error": "User not found in context"})
+		return
+	}
+
+	var body struct {
+		Name     *string
+		Username *string
+		Email    *string
+		Timezone *string
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+		return
+	}
+
+	username := user.Username
+	if body.Username != nil {
+		username = strings.TrimSpace(*body.Username)
+		if username == "" {
+			ctx.JSON(400, gin.H{"error": "Username is required"})
+			return
+		}
+	}
+
+	if body.Timezone != nil {
+		if _, err := loadTimezone(*body.Timezone); err != nil {
+			ctx.JSON(400, gin.H{"error": "Invalid timezone, expected an IANA time zone", "timezone": *body.Timezone})
+			return
+		}
+	}
+
+	_, err := inits.DB.ExecContext(ctx,
+		`UPDATE users SET
+			Name = COALESCE($1, Name),
+			Email = CASE WHEN $2::text IS NULL THEN email ELSE NULLIF($2, '') END,
+			Timezone = COALESCE($5, Timezone),
+			Username = $3
+			WHERE username = $4`,
+		body.Name, body.Email, username, user.Username, body.Timezone)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == uniqueViolation {
+		ctx.JSON(http.StatusConflict, gin.H{"error": "Username already taken"})
+		return
+	} else if err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to update profile", "details": err.Error()})
+		return
+	}
+
+	profile, err := fetchProfile(ctx, username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	response := gin.H{"user": profile}
+
+	if username != user.Username {
+		accessToken, err := tokens.SignAccessToken(username, user.Role, ctx.GetString("session_id"))
+		if err != nil {
+			ctx.JSON(500, gin.H{"error": "error signing token"})
+			return
+		}
+		if ctx.GetString("auth_method") == middlewares.AuthMethodCookie {
+			setSameSite(ctx)
+			ctx.SetCookie("Authorization", accessToken, int(tokens.AccessTokenTTL.Seconds()), "/", domain, secure, true)
+		} else {
+			response["access_token"] = accessToken
+		}
+	}
+	audit.Record(ctx, audit.EventAccountUpdated, username, "previous="+user.Username)
+	ctx.JSON(200, response)
+}
+
+// recentLoginWindow is how long after signing in a session counts as a
+// fresh authentication for accounts that have no password
+const recentLoginWindow = 5 * time.Minute
+
+// hasRecentLogin reports whether the current session signed in within
+// recentLoginWindow. API keys have no session and so never count
+func hasRecentLogin(ctx *gin.Context, username string) (bool, error) {
+	var recent bool
+	err := inits.DB.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM sessions
+			WHERE session_id = $1 AND username = $2 AND revoked_at IS NULL
+				AND created_at > now() - make_interval(secs => $3))`,
+		ctx.GetString("session_id"), username, recentLoginWindow.Seconds()).Scan(&recent)
+	return recent, err
+}
+
+// abortReauthRequired answers a request that needs a fresher login. It is
+// not a credential failure and so does not count towards a lockout
+func abortReauthRequired(ctx *gin.Context) {
+	ctx.JSON(401, gin.H{"error": "Reauthentication required", "message": "Sign in again and retry within 5 minutes"})
+}
+
+// DeleteAccount removes the current user after reauthentication: the
+// password, or for accounts without one (OIDC) a TOTP or recovery code when
+// MFA is enabled, otherwise a login within recentLoginWindow. The last admin
+// cannot delete itself. Submissions, tags, reviews, API keys and sessions
+// are deleted with the user
+func DeleteAccount(ctx *gin.Context) {
+	user, ok := currentUser(ctx)
+	if !ok {
+		ctx.JSON(500, gin.H{"error": "User not found in context"})
+		return
+	}
+
+	var body struct {
+		Password      string
+		Code          string
+		Recovery_Code string
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+		return
+	}
+
+	if abortIfLocked(ctx, user.Username, "account deletion") {
+		return
+	}
+
+	var hash string
+	var secret sql.NullString
+	var mfaEnabled bool
+	var lastStep int64
+	err := inits.DB.QueryRowContext(ctx,
+		"SELECT Password, totp_secret, totp_enabled, totp_last_step FROM users WHERE username = $1",
+		user.Username).Scan(&hash, &secret, &mfaEnabled, &lastStep)
+	if err == sql.ErrNoRows {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	} else if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	var verified bool
+	switch {
+	case hash != unusablePassword:
+		verified = bcrypt.CompareHashAndPassword([]byte(hash), []byte(body.Password)) == nil
+	case mfaEnabled:
+		verified, err = verifySecondFactor(ctx, user.Username, secret.String, lastStep, body.Code, body.Recovery_Code)
+	default:
+		verified, err = hasRecentLogin(ctx, user.Username)
+		if err == nil && !verified {
+			abortReauthRequired(ctx)
+			return
+		}
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if !verified {
+		if err := recordCredentialFailure(ctx, user.Username); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		ctx.JSON(401, gin.H{"error": "Invalid credentials"})
+		return
+	}
+
+	tx, err := inits.DB.BeginTx(ctx, nil)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	last, err := isLastAdmin(ctx, tx, user.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if last {
+		ctx.JSON(http.StatusConflict, gin.H{"error": "Cannot delete the last admin"})
+		return
+	}
+
+	// owned rows reference users with ON DELETE CASCADE
+	result, err := tx.ExecContext(ctx, "DELETE FROM users WHERE username = $1", user.Username)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to delete account", "details": err.Error()})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM login_failures WHERE key = $1", usernameLockKey(user.Username)); err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to delete account", "details": err.Error()})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to delete account", "details": err.Error()})
+		return
+	}
+
+	clearAuthCookies(ctx)
+	audit.Record(ctx, audit.EventAccountDeleted, user.Username, "")
+	ctx.JSON(200, gin.H{"status": "Account deleted successfully"})
+}
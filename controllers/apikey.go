@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"net/http"
+	"reviser/internal/apikeys"
+	"reviser/internal/audit"
+	"reviser/internal/inits"
+	"reviser/internal/models"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateAPIKey issues a new API key for the current user. The plaintext
+// key is only returned once, in this response
+func CreateAPIKey(ctx *gin.Context) {
+	user, ok := currentUser(ctx)
+	if !ok {
+		ctx.JSON(500, gin.H{"error": "User not found in context"})
+		return
+	}
+
+	var body struct {
+		Name   string
+		Scopes models.StringArray
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+		return
+	}
+	if len(body.Scopes) == 0 {
+		ctx.JSON(400, gin.H{"error": "At least one scope is required"})
+		return
+	}
+	for _, scope := range body.Scopes {
+		if !apikeys.ValidScope(scope) {
+			ctx.JSON(400, gin.H{"error": "Invalid scope", "scope": scope})
+			return
+		}
+	}
+
+	key, prefix, hash, err := apikeys.Generate()
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to generate API key"})
+		return
+	}
+
+	apiKey := models.API_Key{Username: user.Username, Name: body.Name, Prefix: prefix, Scopes: body.Scopes}
+	err = inits.DB.QueryRowContext(ctx,
+		`INSERT INTO api_keys (username, name, prefix, key_hash, scopes)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING key_id, created_at`,
+		apiKey.Username, apiKey.Name, apiKey.Prefix, hash, apiKey.Scopes).Scan(&apiKey.Key_ID, &apiKey.Created_At)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to create API key", "details": err.Error()})
+		return
+	}
+
+	audit.Record(ctx, audit.EventAPIKeyCreated, user.Username, "prefix="+apiKey.Prefix)
+	ctx.JSON(200, gin.H{"key": key, "api_key": apiKey})
+}
+
+// apiKeyOrder lists the newest keys first
+var apiKeyOrder = []keysetColumn{
+	{expr: "created_at", cast: "timestamptz", desc: true},
+	{expr: "key_id", cast: "bigint", desc: true},
+}
+
+// FetchAPIKeys lists the API keys of the current user, including revoked ones
+func FetchAPIKeys(ctx *gin.Context) {
+	user, ok := currentUser(ctx)
+	if !ok {
+		ctx.JSON(500, gin.H{"error": "User not found in context"})
+		return
+	}
+
+	page, ok := parsePageRequest(ctx, apiKeyOrder, defaultPageSize, maxPageSize)
+	if !ok {
+		return
+	}
+	after, afterArgs := page.where(2)
+	order, orderArgs := page.orderBy(2 + len(afterArgs))
+
+	query := `
+		SELECT key_id, username, name, prefix, scopes, created_at, last_used_at, revoked_at
+		FROM api_keys
+		WHERE username = $1 AND ` + after + `
+		` + order
+	args := []interface{}{user.Username}
+	rows, err := inits.DB.QueryContext(ctx, query, append(append(args, afterArgs...), orderArgs...)...)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	var keys []models.API_Key
+	for rows.Next() {
+		var k models.API_Key
+		if err := rows.Scan(
+			&k.Key_ID,
+			&k.Username,
+			&k.Name,
+			&k.Prefix,
+			&k.Scopes,
+			&k.Created_At,
+			&k.Last_Used_At,
+			&k.Revoked_At,
+		); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan row"})
+			return
+		}
+		keys = append(keys, k)
+	}
+
+	if err := rows.Err(); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Row iteration error"})
+		return
+	}
+	keys, response := finishPage(page, keys, func(k models.API_Key) []string {
+		return []string{timeKey(k.Created_At), strconv.FormatUint(uint64(k.Key_ID), 10)}
+	})
+	response["api_keys"] = keys
+	ctx.JSON(200, response)
+}
+
+// RevokeAPIKey revokes one of the current user's API keys
+func RevokeAPIKey(ctx *gin.Context) {
+	user, ok := currentUser(ctx)
+	if !ok {
+		ctx.JSON(500, gin.H{"error": "User not found in context"})
+		return
+	}
+
+	keyID, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid API key id"})
+		return
+	}
+
+	result, err := inits.DB.ExecContext(ctx,
+		`UPDATE api_keys SET revoked_at = now()
+			WHERE key_id = $1 AND username = $2 AND revoked_at IS NULL`,
+		keyID, user.Username)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to revoke API key", "details": err.Error()})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+	audit.Record(ctx, audit.EventAPIKeyRevoked, user.Username, "key_id="+strconv.FormatUint(keyID, 10))
+	ctx.JSON(200, gin.H{"status": "API key revoked successfully"})
+}
@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"net/http"
+	"reviser/internal/inits"
+	"reviser/internal/models"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// auditOrder lists the latest events first
+var auditOrder = []keysetColumn{
+	{expr: "created_at", cast: "timestamptz", desc: true},
+	{expr: "event_id", cast: "bigint", desc: true},
+}
+
+// parseOptionalTime parses an RFC 3339 query parameter, returning nil
+// when it is absent
+func parseOptionalTime(ctx *gin.Context, name string) (*time.Time, bool) {
+	value := ctx.Query(name)
+	if value == "" {
+		return nil, true
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid '" + name + "' query parameter, expected RFC 3339"})
+		return nil, false
+	}
+	return &t, true
+}
+
+// FetchAuthEvents lets an admin query the audit log by username, event
+// and time range, newest first
+func FetchAuthEvents(ctx *gin.Context) {
+	from, ok := parseOptionalTime(ctx, "from")
+	if !ok {
+		return
+	}
+	to, ok := parseOptionalTime(ctx, "to")
+	if !ok {
+		return
+	}
+
+	page, ok := parsePageRequest(ctx, auditOrder, defaultAuditLimit, maxAuditLimit)
+	if !ok {
+		return
+	}
+	after, afterArgs := page.where(5)
+	order, orderArgs := page.orderBy(5 + len(afterArgs))
+
+	query := `
+		SELECT event_id, event, username, ip, user_agent, details, created_at
+		FROM auth_events
+		WHERE ($1 = '' OR username = $1)
+			AND ($2 = '' OR event = $2)
+			AND ($3::timestamptz IS NULL OR created_at >= $3)
+			AND ($4::timestamptz IS NULL OR created_at < $4)
+			AND ` + after + `
+		` + order
+	args := []interface{}{ctx.Query("username"), ctx.Query("event"), from, to}
+	rows, err := inits.DB.QueryContext(ctx, query, append(append(args, afterArgs...), orderArgs...)...)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	var events []models.Auth_Event
+	for rows.Next() {
+		var e models.Auth_Event
+		if err := rows.Scan(
+			&e.Event_ID,
+			&e.Event,
+			&e.Username,
+			&e.IP,
+			&e.User_Agent,
+			&e.Details,
+			&e.Created_At,
+		); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan row"})
+			return
+		}
+		events = append(events, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Row iteration error"})
+		return
+	}
+
+	events, response := finishPage(page, events, func(e models.Auth_Event) []string {
+		return []string{timeKey(e.Created_At), strconv.FormatUint(uint64(e.Event_ID), 10)}
+	})
+	response["events"] = events
+	ctx.JSON(200, response)
+}
@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"reviser/internal/diff"
+	"reviser/internal/inits"
+	"reviser/internal/models"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultDiffContext = 3
+
+// findSubmission returns the first of the user's submissions selected by
+// the conditions, which may reference args from $3 on
+func findSubmission(ctx *gin.Context, username, slug, conditions string, args ...interface{}) (models.Leetcode_submissions, error) {
+	var s models.Leetcode_submissions
+	err := inits.DB.QueryRowContext(ctx, `
+		SELECT `+submissionColumns+`
+		FROM leetcode_submissions s
+		WHERE s.username = $1 AND s.question_slug = $2 `+conditions+`
+		ORDER BY s.submitted_at DESC, s.submission_id DESC
+		LIMIT 1`,
+		append([]interface{}{username, slug}, args...)...).Scan(submissionFields(&s)...)
+	return s, err
+}
+
+// parseSubmissionID reads an optional submission id query parameter
+func parseSubmissionID(ctx *gin.Context, name string) (*uint64, bool) {
+	value := ctx.Query(name)
+	if value == "" {
+		return nil, true
+	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid '" + name + "' query parameter"})
+		return nil, false
+	}
+	return &id, true
+}
+
+// FetchSubmissionDiff compares two submissions of the same question and
+// returns a unified diff along with a line-level diff for rendering. to
+// defaults to the latest attempt and from to the attempt before to
+func FetchSubmissionDiff(ctx *gin.Context) {
+	user, ok := currentUser(ctx)
+	if !ok {
+		ctx.JSON(500, gin.H{"error": "User not found in context"})
+		return
+	}
+
+	slug := ctx.Param("slug")
+	fromID, ok := parseSubmissionID(ctx, "from")
+	if !ok {
+		return
+	}
+	toID, ok := parseSubmissionID(ctx, "to")
+	if !ok {
+		return
+	}
+	contextLines := defaultDiffContext
+	if value := ctx.Query("context"); value != "" {
+		var err error
+		if contextLines, err = strconv.Atoi(value); err != nil || contextLines < 0 {
+			ctx.JSON(400, gin.H{"error": "invalid 'context' query parameter"})
+			return
+		}
+	}
+
+	var to models.Leetcode_submissions
+	var err error
+	if toID != nil {
+		to, err = findSubmission(ctx, user.Username, slug, "AND submission_id = $3", *toID)
+	} else {
+		to, err = findSubmission(ctx, user.Username, slug, "")
+	}
+	if err == sql.ErrNoRows {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Submission not found"})
+		return
+	} else if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	var from models.Leetcode_submissions
+	if fromID != nil {
+		from, err = findSubmission(ctx, user.Username, slug, "AND submission_id = $3", *fromID)
+	} else {
+		from, err = findSubmission(ctx, user.Username, slug,
+			"AND (submitted_at, submission_id) < ($3, $4)", to.Submitted_At, to.Submission_ID)
+	}
+	if err == sql.ErrNoRows {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "No earlier submission to compare with"})
+		return
+	} else if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	lines := diff.Lines(from.Code, to.Code)
+	unified := diff.Unified(lines,
+		fmt.Sprintf("%s/%d", slug, from.Submission_ID), fmt.Sprintf("%s/%d", slug, to.Submission_ID), contextLines)
+
+	type attempt struct {
+		Submission_ID uint
+		Submitted_At  time.Time
+		Language      string
+		Status        string
+	}
+	ctx.JSON(200, gin.H{
+		"from":    attempt{from.Submission_ID, from.Submitted_At, from.Language, from.Status},
+		"to":      attempt{to.Submission_ID, to.Submitted_At, to.Language, to.Status},
+		"unified": unified,
+		"lines":   lines,
+	})
+}
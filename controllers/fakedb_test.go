@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reviser/internal/inits"
+	"reviser/internal/models"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeResult answers the statements containing match. Queries return
+// rows under columns, statements affect affected rows
+type fakeResult struct {
+	match    string
+	columns  []string
+	rows     [][]driver.Value
+	affected int64
+}
+
+// fakeDB is a database/sql driver answering statements from a script, so
+// handlers run without Postgres. The first matching result wins, queries
+// without one return no rows and other statements affect one row
+type fakeDB struct {
+	mu       sync.Mutex
+	script   []fakeResult
+	executed []string
+}
+
+// useFakeDB points inits.DB at a fakeDB answering with script
+func useFakeDB(t *testing.T, script ...fakeResult) *fakeDB {
+	t.Helper()
+	db := &fakeDB{script: script}
+	previous := inits.DB
+	inits.DB = sql.OpenDB(db)
+	t.Cleanup(func() {
+		inits.DB.Close()
+		inits.DB = previous
+	})
+	return db
+}
+
+// ran reports whether a statement containing match was executed
+func (db *fakeDB) ran(match string) bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for _, statement := range db.executed {
+		if strings.Contains(statement, match) {
+			return true
+		}
+	}
+	return false
+}
+
+func (db *fakeDB) answer(statement string) fakeResult {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.executed = append(db.executed, statement)
+	for _, result := range db.script {
+		if strings.Contains(statement, result.match) {
+			return result
+		}
+	}
+	return fakeResult{affected: 1}
+}
+
+func (db *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{db}, nil }
+func (db *fakeDB) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c.db, query}, nil }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)                 { return fakeTx{}, nil }
+
+func (c fakeConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	result := c.db.answer(query)
+	return &fakeRows{columns: result.columns, rows: result.rows}, nil
+}
+
+func (c fakeConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(c.db.answer(query).affected), nil
+}
+
+// CheckNamedValue accepts every argument, as lib/pq does for its own types
+func (c fakeConn) CheckNamedValue(v *driver.NamedValue) error {
+	if valuer, ok := v.Value.(driver.Valuer); ok {
+		value, err := valuer.Value()
+		v.Value = value
+		return err
+	}
+	return nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+func (s fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(s.db.answer(s.query).affected), nil
+}
+func (s fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	result := s.db.answer(s.query)
+	return &fakeRows{columns: result.columns, rows: result.rows}, nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// notLocked answers the lockout check of abortIfLocked
+var notLocked = fakeResult{match: "MAX(locked_until)", columns: []string{"max"}, rows: [][]driver.Value{{nil}}}
+
+// oneFailure answers the counter update of recordLoginFailure
+var oneFailure = fakeResult{match: "RETURNING failures", columns: []string{"failures"}, rows: [][]driver.Value{{int64(1)}}}
+
+// serve runs handler for user with a JSON body and decodes the response
+func serve(t *testing.T, handler gin.HandlerFunc, user models.User, sessionID string, method, target string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = strings.NewReader(string(data))
+	}
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Request = httptest.NewRequest(method, target, reader)
+	ctx.Request.Header.Set("Content-Type", "application/json")
+	ctx.Set("user", user)
+	if sessionID != "" {
+		ctx.Set("session_id", sessionID)
+	}
+	handler(ctx)
+
+	var response map[string]interface{}
+	if recorder.Body.Len() > 0 {
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("response %q: %v", recorder.Body.String(), err)
+		}
+	}
+	if recorder.Code == 0 {
+		return http.StatusOK, response
+	}
+	return recorder.Code, response
+}
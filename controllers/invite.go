@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"net/http"
+	"os"
+	"reviser/internal/audit"
+	"reviser/internal/inits"
+	"reviser/internal/models"
+	"reviser/internal/tokens"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Signup modes selected with SIGNUP_MODE. A deployment without an admin
+// always accepts its first signup so it can be bootstrapped
+const (
+	signupOpen   = "open"
+	signupInvite = "invite"
+	signupClosed = "closed"
+)
+
+const (
+	defaultInviteTTL = 72 * time.Hour
+	maxInviteTTL     = 90 * 24 * time.Hour
+)
+
+func signupMode() string {
+	switch mode := os.Getenv("SIGNUP_MODE"); mode {
+	case signupInvite, signupClosed:
+		return mode
+	default:
+		return signupOpen
+	}
+}
+
+// CreateInvite lets an admin generate an invite code. The code is only
+// returned once, in this response
+func CreateInvite(ctx *gin.Context) {
+	admin, ok := currentUser(ctx)
+	if !ok {
+		ctx.JSON(500, gin.H{"error": "User not found in context"})
+		return
+	}
+
+	var body struct {
+		Note             string
+		Max_Uses         int
+		Expires_In_Hours int
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+		return
+	}
+	if body.Max_Uses == 0 {
+		body.Max_Uses = 1
+	}
+	if body.Max_Uses < 0 {
+		ctx.JSON(400, gin.H{"error": "Max_Uses must be positive"})
+		return
+	}
+	ttl := defaultInviteTTL
+	if body.Expires_In_Hours != 0 {
+		// checked before converting, a huge value would overflow into range
+		if body.Expires_In_Hours < 1 || body.Expires_In_Hours > int(maxInviteTTL/time.Hour) {
+			ctx.JSON(400, gin.H{"error": "Expires_In_Hours must be between 1 and 2160"})
+			return
+		}
+		ttl = time.Duration(body.Expires_In_Hours) * time.Hour
+	}
+
+	code, hash, err := tokens.NewOpaqueToken()
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to generate invite code"})
+		return
+	}
+
+	invite := models.Invite{
+		Prefix:     code[:8],
+		Note:       body.Note,
+		Created_By: admin.Username,
+		Max_Uses:   body.Max_Uses,
+		Expires_At: time.Now().Add(ttl),
+	}
+	err = inits.DB.QueryRowContext(ctx,
+		`INSERT INTO invites (code_hash, prefix, note, created_by, max_uses, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING invite_id, created_at`,
+		hash, invite.Prefix, invite.Note, invite.Created_By, invite.Max_Uses, invite.Expires_At).Scan(
+		&invite.Invite_ID, &invite.Created_At)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to create invite", "details": err.Error()})
+		return
+	}
+
+	audit.Record(ctx, audit.EventInviteCreated, admin.Username, "invite_id="+strconv.FormatUint(uint64(invite.Invite_ID), 10))
+	ctx.JSON(200, gin.H{"code": code, "invite": invite})
+}
+
+// inviteOrder lists the newest invites first
+var inviteOrder = []keysetColumn{
+	{expr: "i.created_at", cast: "timestamptz", desc: true},
+	{expr: "i.invite_id", cast: "bigint", desc: true},
+}
+
+// FetchInvites lists the invites with the accounts created from them
+func FetchInvites(ctx *gin.Context) {
+	page, ok := parsePageRequest(ctx, inviteOrder, defaultPageSize, maxPageSize)
+	if !ok {
+		return
+	}
+	after, afterArgs := page.where(1)
+	order, orderArgs := page.orderBy(1 + len(afterArgs))
+
+	query := `
+		SELECT
+			i.invite_id, i.prefix, i.note, i.created_by, i.max_uses, i.uses,
+			i.expires_at, i.created_at, i.revoked_at,
+			COALESCE(json_agg(u.username) FILTER (WHERE u.username IS NOT NULL), '[]')
+		FROM invites i
+		LEFT JOIN users u ON u.invite_id = i.invite_id
+		WHERE ` + after + `
+		GROUP BY i.invite_id
+		` + order
+	rows, err := inits.DB.QueryContext(ctx, query, append(afterArgs, orderArgs...)...)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	var invites []models.Invite
+	for rows.Next() {
+		var i models.Invite
+		if err := rows.Scan(
+			&i.Invite_ID,
+			&i.Prefix,
+			&i.Note,
+			&i.Created_By,
+			&i.Max_Uses,
+			&i.Uses,
+			&i.Expires_At,
+			&i.Created_At,
+			&i.Revoked_At,
+			&i.Accounts,
+		); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan row"})
+			return
+		}
+		invites = append(invites, i)
+	}
+
+	if err := rows.Err(); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Row iteration error"})
+		return
+	}
+	invites, response := finishPage(page, invites, func(i models.Invite) []string {
+		return []string{timeKey(i.Created_At), strconv.FormatUint(uint64(i.Invite_ID), 10)}
+	})
+	response["invites"] = invites
+	response["signup_mode"] = signupMode()
+	ctx.JSON(200, response)
+}
+
+// RevokeInvite stops an invite from being redeemed again
+func RevokeInvite(ctx *gin.Context) {
+	inviteID, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid invite id"})
+		return
+	}
+
+	result, err := inits.DB.ExecContext(ctx,
+		"UPDATE invites SET revoked_at = now() WHERE invite_id = $1 AND revoked_at IS NULL", inviteID)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to revoke invite", "details": err.Error()})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+		return
+	}
+	admin, _ := currentUser(ctx)
+	audit.Record(ctx, audit.EventInviteRevoked, admin.Username, "invite_id="+strconv.FormatUint(inviteID, 10))
+	ctx.JSON(200, gin.H{"status": "Invite revoked successfully"})
+}
@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"database/sql/driver"
+	"reviser/internal/models"
+	"testing"
+	"time"
+)
+
+func TestCreateInviteExpiry(t *testing.T) {
+	admin := models.User{Username: "admin", Role: "admin"}
+	tests := []struct {
+		name       string
+		hours      int
+		wantStatus int
+	}{
+		{"default", 0, 200},
+		{"one hour", 1, 200},
+		{"maximum", 2160, 200},
+		{"negative", -1, 400},
+		{"over the maximum", 2161, 400},
+		// times time.Hour this wraps around to exactly 24 hours
+		{"overflowing", 1<<51 + 24, 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := useFakeDB(t, fakeResult{
+				match:   "INSERT INTO invites",
+				columns: []string{"invite_id", "created_at"},
+				rows:    [][]driver.Value{{int64(1), time.Now()}},
+			})
+			body := map[string]int{"Expires_In_Hours": tt.hours}
+			status, response := serve(t, CreateInvite, admin, "", "POST", "/api/admin/invites", body)
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %v", status, tt.wantStatus, response)
+			}
+			if created := db.ran("INSERT INTO invites"); created != (tt.wantStatus == 200) {
+				t.Errorf("created an invite = %v", created)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package controllers
+
+import (
+	"reviser/internal/tokens"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKS publishes the public keys tokens are signed with so other
+// services can verify them
+func JWKS(ctx *gin.Context) {
+	jwks, err := tokens.JWKS()
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to load keys"})
+		return
+	}
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(200, jwks)
+}
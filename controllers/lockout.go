@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"reviser/internal/audit"
+	"reviser/internal/inits"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// Failed logins are counted per username and per client IP. Once a key
+// reaches its threshold it is locked for lockoutBase, doubling with every
+// further failure up to lockoutMax. Counters reset after failureWindow
+// without failures
+const (
+	usernameFailureThreshold = 5
+	ipFailureThreshold       = 20
+	lockoutBase              = time.Minute
+	lockoutMax               = time.Hour
+	failureWindow            = 15 * time.Minute
+)
+
+func usernameLockKey(username string) string {
+	return "user:" + username
+}
+
+func ipLockKey(ip string) string {
+	return "ip:" + ip
+}
+
+// lockDuration returns how long a key is locked after its nth failure
+func lockDuration(failures int, threshold int) time.Duration {
+	if failures < threshold {
+		return 0
+	}
+	exponent := float64(failures - threshold)
+	if exponent > 16 {
+		return lockoutMax
+	}
+	d := lockoutBase * time.Duration(math.Pow(2, exponent))
+	if d > lockoutMax {
+		return lockoutMax
+	}
+	return d
+}
+
+// lockedUntil returns the latest lock expiry among keys that are still locked
+func lockedUntil(ctx context.Context, keys ...string) (time.Time, error) {
+	var until *time.Time
+	err := inits.DB.QueryRowContext(ctx,
+		`SELECT MAX(locked_until) FROM login_failures
+			WHERE key = ANY($1) AND locked_until > now()`,
+		pq.Array(keys)).Scan(&until)
+	if err != nil || until == nil {
+		return time.Time{}, err
+	}
+	return *until, nil
+}
+
+// recordLoginFailure increments the failure counter of key and locks it
+// once it crosses threshold
+func recordLoginFailure(ctx context.Context, key string, threshold int) error {
+	var failures int
+	err := inits.DB.QueryRowContext(ctx,
+		`INSERT INTO login_failures (key, failures, last_failure_at)
+			VALUES ($1, 1, now())
+			ON CONFLICT (key) DO UPDATE SET
+				failures = CASE
+					WHEN login_failures.last_failure_at < now() - make_interval(secs => $2)
+						AND (login_failures.locked_until IS NULL OR login_failures.locked_until < now())
+					THEN 1
+					ELSE login_failures.failures + 1
+				END,
+				last_failure_at = now()
+			RETURNING failures`,
+		key, failureWindow.Seconds()).Scan(&failures)
+	if err != nil {
+		return err
+	}
+
+	if d := lockDuration(failures, threshold); d > 0 {
+		_, err = inits.DB.ExecContext(ctx,
+			"UPDATE login_failures SET locked_until = $2 WHERE key = $1",
+			key, time.Now().Add(d))
+	}
+	return err
+}
+
+func clearLoginFailures(ctx context.Context, key string) error {
+	_, err := inits.DB.ExecContext(ctx, "DELETE FROM login_failures WHERE key = $1", key)
+	return err
+}
+
+// abortIfLocked answers 429 and returns true while the user or the client
+// IP is locked. Every endpoint that checks a credential of a known user
+// goes through it, not only Login
+func abortIfLocked(ctx *gin.Context, username string, details string) bool {
+	until, err := lockedUntil(ctx, usernameLockKey(username), ipLockKey(ctx.ClientIP()))
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "Database error"})
+		return true
+	}
+	if until.IsZero() {
+		return false
+	}
+	retryAfter := int(math.Ceil(time.Until(until).Seconds()))
+	ctx.Header("Retry-After", strconv.Itoa(retryAfter))
+	audit.Record(ctx, audit.EventLoginLocked, username, details)
+	ctx.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed attempts", "retry_after": retryAfter})
+	return true
+}
+
+// recordCredentialFailure counts a wrong password or code against both the
+// user and the client IP
+func recordCredentialFailure(ctx *gin.Context, username string) error {
+	if err := recordLoginFailure(ctx, usernameLockKey(username), usernameFailureThreshold); err != nil {
+		return err
+	}
+	return recordLoginFailure(ctx, ipLockKey(ctx.ClientIP()), ipFailureThreshold)
+}
@@ -0,0 +1,347 @@
+package controllers
+
+import (
+	"database/sql"
+	"net/http"
+	"os"
+	"reviser/internal/audit"
+	"reviser/internal/inits"
+	"reviser/internal/models"
+	"reviser/internal/tokens"
+	"reviser/internal/totp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const recoveryCodeCount = 10
+
+func mfaIssuer() string {
+	if issuer := os.Getenv("MFA_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "Reviser"
+}
+
+// EnrollMFA generates a new TOTP secret for the current user. The secret
+// is only enforced once it has been confirmed with ConfirmMFA
+func EnrollMFA(ctx *gin.Context) {
+	user, ok := currentUser(ctx)
+	if !ok {
+		ctx.JSON(500, gin.H{"error": "User not found in context"})
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+
+	result, err := inits.DB.ExecContext(ctx,
+		"UPDATE users SET totp_secret = $1 WHERE username = $2 AND NOT totp_enabled",
+		secret, user.Username)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to start enrollment", "details": err.Error()})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		ctx.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	ctx.JSON(200, gin.H{"secret": secret, "uri": totp.URI(mfaIssuer(), user.Username, secret)})
+}
+
+// ConfirmMFA enables TOTP once the user proves their authenticator
+// produces valid codes, and returns a fresh set of recovery codes
+func ConfirmMFA(ctx *gin.Context) {
+	user, ok := currentUser(ctx)
+	if !ok {
+		ctx.JSON(500, gin.H{"error": "User not found in context"})
+		return
+	}
+
+	var body struct {
+		Code string
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+		return
+	}
+
+	tx, err := inits.DB.BeginTx(ctx, nil)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	var secret sql.NullString
+	var enabled bool
+	var lastStep int64
+	err = tx.QueryRowContext(ctx,
+		"SELECT totp_secret, totp_enabled, totp_last_step FROM users WHERE username = $1 FOR UPDATE",
+		user.Username).Scan(&secret, &enabled, &lastStep)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if enabled {
+		ctx.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	if !secret.Valid {
+		ctx.JSON(400, gin.H{"error": "Enrollment has not been started"})
+		return
+	}
+
+	step, valid := totp.Validate(secret.String, body.Code, time.Now(), lastStep)
+	if !valid {
+		ctx.JSON(401, gin.H{"error": "Invalid code"})
+		return
+	}
+
+	codes, err := totp.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE users SET totp_enabled = true, totp_last_step = $1 WHERE username = $2",
+		step, user.Username); err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to enable two-factor authentication", "details": err.Error()})
+		return
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM mfa_recovery_codes WHERE username = $1", user.Username); err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to store recovery codes", "details": err.Error()})
+		return
+	}
+	for _, code := range codes {
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO mfa_recovery_codes (code_hash, username) VALUES ($1, $2)",
+			tokens.HashOpaqueToken(totp.NormalizeRecoveryCode(code)), user.Username); err != nil {
+			ctx.JSON(500, gin.H{"error": "Failed to store recovery codes", "details": err.Error()})
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to enable two-factor authentication", "details": err.Error()})
+		return
+	}
+
+	audit.Record(ctx, audit.EventMFAEnabled, user.Username, "")
+	ctx.JSON(200, gin.H{"status": "Two-factor authentication enabled", "recovery_codes": codes})
+}
+
+// DisableMFA turns TOTP off after re-verifying the user's password and a
+// current TOTP or recovery code, so a stolen password alone is not enough.
+// Accounts without a password (OIDC) need only the second factor
+func DisableMFA(ctx *gin.Context) {
+	user, ok := currentUser(ctx)
+	if !ok {
+		ctx.JSON(500, gin.H{"error": "User not found in context"})
+		return
+	}
+
+	var body struct {
+		Password      string
+		Code          string
+		Recovery_Code string
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+		return
+	}
+
+	if abortIfLocked(ctx, user.Username, "mfa disable") {
+		return
+	}
+
+	var hash string
+	var secret sql.NullString
+	var enabled bool
+	var lastStep int64
+	err := inits.DB.QueryRowContext(ctx,
+		"SELECT Password, totp_secret, totp_enabled, totp_last_step FROM users WHERE username = $1",
+		user.Username).Scan(&hash, &secret, &enabled, &lastStep)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	// accounts without a password (OIDC) reauthenticate with the second
+	// factor below, or a recent login when MFA is already off
+	if hash == unusablePassword && !enabled {
+		recent, err := hasRecentLogin(ctx, user.Username)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if !recent {
+			abortReauthRequired(ctx)
+			return
+		}
+	}
+	if hash != unusablePassword && bcrypt.CompareHashAndPassword([]byte(hash), []byte(body.Password)) != nil {
+		if err := recordCredentialFailure(ctx, user.Username); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		audit.Record(ctx, audit.EventMFAFailure, user.Username, "disable: invalid password")
+		ctx.JSON(401, gin.H{"error": "Invalid credentials"})
+		return
+	}
+	if enabled {
+		verified, err := verifySecondFactor(ctx, user.Username, secret.String, lastStep, body.Code, body.Recovery_Code)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if !verified {
+			if err := recordCredentialFailure(ctx, user.Username); err != nil {
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+				return
+			}
+			audit.Record(ctx, audit.EventMFAFailure, user.Username, "disable: invalid code")
+			ctx.JSON(401, gin.H{"error": "Invalid code"})
+			return
+		}
+	}
+	if err := clearLoginFailures(ctx, usernameLockKey(user.Username)); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	tx, err := inits.DB.BeginTx(ctx, nil)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE users SET totp_enabled = false, totp_secret = NULL, totp_last_step = 0 WHERE username = $1",
+		user.Username); err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to disable two-factor authentication", "details": err.Error()})
+		return
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM mfa_recovery_codes WHERE username = $1", user.Username); err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to disable two-factor authentication", "details": err.Error()})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to disable two-factor authentication", "details": err.Error()})
+		return
+	}
+
+	audit.Record(ctx, audit.EventMFADisabled, user.Username, "")
+	ctx.JSON(200, gin.H{"status": "Two-factor authentication disabled"})
+}
+
+// VerifyMFA completes a two-phase login by exchanging the MFA pending
+// token from Login and a TOTP or recovery code for a session
+func VerifyMFA(ctx *gin.Context) {
+	var body struct {
+		Mfa_Token     string
+		Code          string
+		Recovery_Code string
+		Auth_Mode     string
+	}
+	if ctx.BindJSON(&body) != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid request", "message": "Error trying to parse body!"})
+		return
+	}
+	if !validAuthMode(body.Auth_Mode) {
+		ctx.JSON(400, gin.H{"error": "Invalid request", "message": "Auth_Mode must be cookie or header"})
+		return
+	}
+
+	username, err := tokens.ParseMFAPendingToken(body.Mfa_Token)
+	if err != nil {
+		ctx.JSON(401, gin.H{"error": "unauthorized", "message": "Invalid or expired MFA token"})
+		return
+	}
+
+	if abortIfLocked(ctx, username, "mfa") {
+		return
+	}
+
+	var user models.User
+	var secret sql.NullString
+	var lastStep int64
+	err = inits.DB.QueryRowContext(ctx,
+		`SELECT Name, Username, Role, totp_secret, totp_last_step
+			FROM users WHERE username = $1 AND totp_enabled`,
+		username).Scan(&user.Name, &user.Username, &user.Role, &secret, &lastStep)
+	if err == sql.ErrNoRows {
+		ctx.JSON(401, gin.H{"error": "unauthorized", "message": "Invalid or expired MFA token"})
+		return
+	} else if err != nil {
+		ctx.JSON(500, gin.H{"error": "Database error"})
+		return
+	}
+
+	verified, err := verifySecondFactor(ctx, username, secret.String, lastStep, body.Code, body.Recovery_Code)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "Database error"})
+		return
+	}
+
+	if !verified {
+		if err := recordCredentialFailure(ctx, username); err != nil {
+			ctx.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+		audit.Record(ctx, audit.EventMFAFailure, username, "")
+		ctx.JSON(401, gin.H{"error": "Invalid code"})
+		return
+	}
+
+	if err := clearLoginFailures(ctx, usernameLockKey(username)); err != nil {
+		ctx.JSON(500, gin.H{"error": "Database error"})
+		return
+	}
+
+	session, err := startSession(ctx, user)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "error signing token"})
+		return
+	}
+	audit.Record(ctx, audit.EventLoginSuccess, user.Username, "mfa")
+	ctx.JSON(200, deliverTokens(ctx, body.Auth_Mode, session,
+		gin.H{"data": "Successfully logged in!", "user": user.Username}))
+}
+
+// verifySecondFactor checks a TOTP code or a recovery code of the user and
+// consumes it, so each code is accepted at most once
+func verifySecondFactor(ctx *gin.Context, username, secret string, lastStep int64, code, recoveryCode string) (bool, error) {
+	switch {
+	case code != "":
+		step, ok := totp.Validate(secret, code, time.Now(), lastStep)
+		if !ok {
+			return false, nil
+		}
+		// the conditional update stops two concurrent requests using the same code
+		result, err := inits.DB.ExecContext(ctx,
+			"UPDATE users SET totp_last_step = $1 WHERE username = $2 AND totp_last_step < $1",
+			step, username)
+		if err != nil {
+			return false, err
+		}
+		n, _ := result.RowsAffected()
+		return n == 1, nil
+	case recoveryCode != "":
+		result, err := inits.DB.ExecContext(ctx,
+			`UPDATE mfa_recovery_codes SET used_at = now()
+				WHERE code_hash = $1 AND username = $2 AND used_at IS NULL`,
+			tokens.HashOpaqueToken(totp.NormalizeRecoveryCode(recoveryCode)), username)
+		if err != nil {
+			return false, err
+		}
+		n, _ := result.RowsAffected()
+		return n == 1, nil
+	}
+	return false, nil
+}
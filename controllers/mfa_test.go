@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"database/sql/driver"
+	"reviser/internal/models"
+	"reviser/internal/totp"
+	"testing"
+	"time"
+)
+
+const testSecret = "JBSWY3DPEHPK3PXP"
+
+func passwordlessUser(mfaEnabled bool) fakeResult {
+	secret := driver.Value(nil)
+	if mfaEnabled {
+		secret = testSecret
+	}
+	return fakeResult{
+		match:   "SELECT Password, totp_secret, totp_enabled, totp_last_step FROM users",
+		columns: []string{"password", "totp_secret", "totp_enabled", "totp_last_step"},
+		rows:    [][]driver.Value{{unusablePassword, secret, mfaEnabled, int64(0)}},
+	}
+}
+
+func recentLogin(recent bool) fakeResult {
+	return fakeResult{match: "FROM sessions", columns: []string{"exists"}, rows: [][]driver.Value{{recent}}}
+}
+
+func TestDisableMFAWithoutPassword(t *testing.T) {
+	code, err := totp.CodeAt(testSecret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{Username: "oidc-user", Role: "user"}
+
+	tests := []struct {
+		name         string
+		script       []fakeResult
+		body         map[string]string
+		wantStatus   int
+		wantFailure  bool
+		wantDisabled bool
+	}{
+		{
+			name:         "valid code",
+			script:       []fakeResult{notLocked, passwordlessUser(true)},
+			body:         map[string]string{"Code": code},
+			wantStatus:   200,
+			wantDisabled: true,
+		},
+		{
+			name:        "wrong code",
+			script:      []fakeResult{notLocked, passwordlessUser(true), {match: "SET totp_last_step", affected: 0}, oneFailure},
+			body:        map[string]string{"Code": "000000"},
+			wantStatus:  401,
+			wantFailure: true,
+		},
+		{
+			name:         "recent login without MFA",
+			script:       []fakeResult{notLocked, passwordlessUser(false), recentLogin(true)},
+			body:         map[string]string{},
+			wantStatus:   200,
+			wantDisabled: true,
+		},
+		{
+			name:       "old login without MFA",
+			script:     []fakeResult{notLocked, passwordlessUser(false), recentLogin(false)},
+			body:       map[string]string{"Password": "anything"},
+			wantStatus: 401,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := useFakeDB(t, tt.script...)
+			status, response := serve(t, DisableMFA, user, "session-1", "POST", "/mfa/disable", tt.body)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %v", status, tt.wantStatus, response)
+			}
+			if got := db.ran("INSERT INTO login_failures"); got != tt.wantFailure {
+				t.Errorf("recorded a credential failure = %v, want %v", got, tt.wantFailure)
+			}
+			if got := db.ran("SET totp_enabled = false"); got != tt.wantDisabled {
+				t.Errorf("disabled MFA = %v, want %v", got, tt.wantDisabled)
+			}
+		})
+	}
+}
@@ -0,0 +1,346 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"reviser/internal/audit"
+	"reviser/internal/inits"
+	"reviser/internal/models"
+	"reviser/internal/oidc"
+	"reviser/internal/rbac"
+	"reviser/internal/tokens"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OIDC flows expire after oidcStateTTL. The state is also bound to the
+// browser that started the flow through a cookie holding its hash, so a
+// callback URL cannot be replayed in another browser (login CSRF)
+const (
+	oidcStateTTL    = 10 * time.Minute
+	oidcStateCookie = "oidc_state"
+	oidcStartLimit  = 30
+	oidcStartWindow = 10 * time.Minute
+)
+
+// unusablePassword is stored for accounts provisioned through OIDC. It never
+// matches a bcrypt hash, so password login stays disabled for them
+const unusablePassword = "!"
+
+// errSignupDisabled is returned when an unknown identity would need a new
+// account but SIGNUP_MODE does not allow open signups
+var errSignupDisabled = errors.New("signup is not open")
+
+// errIdentityTaken is returned when linking an identity that already
+// belongs to another account
+var errIdentityTaken = errors.New("identity is linked to another account")
+
+// beginOIDC stores a fresh state, nonce and PKCE verifier, binds the state
+// to the browser and returns the authorization URL. linkUsername is set
+// when a signed in user links an identity to their account
+func beginOIDC(ctx *gin.Context, linkUsername *string) (string, bool) {
+	state, err := oidc.RandomString(32)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to start OIDC login"})
+		return "", false
+	}
+	nonce, err := oidc.RandomString(32)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to start OIDC login"})
+		return "", false
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to start OIDC login"})
+		return "", false
+	}
+
+	authURL, err := inits.OIDC.AuthCodeURL(ctx, state, nonce, challenge)
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"error": "Failed to reach identity provider", "details": err.Error()})
+		return "", false
+	}
+
+	// abandoned flows are purged here so the table stays bounded
+	if _, err := inits.DB.ExecContext(ctx,
+		"DELETE FROM oidc_states WHERE created_at < now() - make_interval(secs => $1)",
+		oidcStateTTL.Seconds()); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return "", false
+	}
+	_, err = inits.DB.ExecContext(ctx,
+		"INSERT INTO oidc_states (state, nonce, code_verifier, link_username) VALUES ($1, $2, $3, $4)",
+		state, nonce, verifier, linkUsername)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return "", false
+	}
+
+	// Lax so the cookie comes back on the top-level redirect from the provider
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(oidcStateCookie, tokens.HashOpaqueToken(state), int(oidcStateTTL.Seconds()), "/auth/oidc", domain, secure, true)
+	return authURL, true
+}
+
+// OIDCStart redirects the user to the identity provider with a fresh
+// state, nonce and PKCE challenge
+func OIDCStart(ctx *gin.Context) {
+	if inits.OIDC == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "OIDC login is not configured"})
+		return
+	}
+
+	wait, err := rateLimited(ctx, "oidc:ip:"+ctx.ClientIP(), oidcStartLimit, oidcStartWindow)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if wait > 0 {
+		abortRateLimited(ctx, wait)
+		return
+	}
+
+	authURL, ok := beginOIDC(ctx, nil)
+	if !ok {
+		return
+	}
+	ctx.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCLink starts a flow that links an identity at the provider to the
+// current account. It answers with the URL to send the browser to, as a
+// redirect from a POST is not followed by every client
+func OIDCLink(ctx *gin.Context) {
+	user, ok := currentUser(ctx)
+	if !ok {
+		ctx.JSON(500, gin.H{"error": "User not found in context"})
+		return
+	}
+	if inits.OIDC == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "OIDC login is not configured"})
+		return
+	}
+
+	authURL, ok := beginOIDC(ctx, &user.Username)
+	if !ok {
+		return
+	}
+	ctx.JSON(200, gin.H{"redirect_url": authURL})
+}
+
+// OIDCCallback redeems the authorization code and either links the
+// identity to the account that started the flow, or signs in the linked
+// or newly provisioned user the same way as Login
+func OIDCCallback(ctx *gin.Context) {
+	if inits.OIDC == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "OIDC login is not configured"})
+		return
+	}
+
+	state := ctx.Query("state")
+	cookie, _ := ctx.Cookie(oidcStateCookie)
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(oidcStateCookie, "", -1, "/auth/oidc", domain, secure, true)
+	if cookie == "" || subtle.ConstantTimeCompare([]byte(cookie), []byte(tokens.HashOpaqueToken(state))) != 1 {
+		ctx.JSON(400, gin.H{"error": "Invalid or expired state"})
+		return
+	}
+
+	var nonce, verifier string
+	var linkUsername sql.NullString
+	err := inits.DB.QueryRowContext(ctx,
+		`DELETE FROM oidc_states
+			WHERE state = $1 AND created_at > now() - make_interval(secs => $2)
+			RETURNING nonce, code_verifier, link_username`,
+		state, oidcStateTTL.Seconds()).Scan(&nonce, &verifier, &linkUsername)
+	if err == sql.ErrNoRows {
+		ctx.JSON(400, gin.H{"error": "Invalid or expired state"})
+		return
+	} else if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if errCode := ctx.Query("error"); errCode != "" {
+		ctx.JSON(401, gin.H{"error": "unauthorized", "message": "Identity provider returned " + errCode})
+		return
+	}
+
+	claims, err := inits.OIDC.Exchange(ctx, ctx.Query("code"), verifier, nonce)
+	if err != nil {
+		audit.Record(ctx, audit.EventLoginFailure, linkUsername.String, "oidc: "+err.Error())
+		ctx.JSON(401, gin.H{"error": "unauthorized", "message": "OIDC login failed"})
+		return
+	}
+	redirect := os.Getenv("OIDC_POST_LOGIN_REDIRECT")
+
+	if linkUsername.Valid {
+		err := linkOIDCIdentity(ctx, linkUsername.String, claims)
+		if err == errIdentityTaken {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "This identity is already linked to another account"})
+			return
+		} else if err != nil {
+			ctx.JSON(500, gin.H{"error": "Failed to link account", "details": err.Error()})
+			return
+		}
+		audit.Record(ctx, audit.EventIdentityLinked, linkUsername.String, "oidc: "+inits.OIDC.Issuer)
+		if redirect != "" {
+			ctx.Redirect(http.StatusFound, redirect)
+			return
+		}
+		ctx.JSON(200, gin.H{"status": "Identity linked successfully"})
+		return
+	}
+
+	user, err := oidcUser(ctx, claims)
+	if err == errSignupDisabled {
+		ctx.JSON(403, gin.H{"error": "forbidden", "message": "No account is linked to this identity and signup is not open"})
+		return
+	} else if err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to link account", "details": err.Error()})
+		return
+	}
+
+	// the provider only replaces the password, a second factor is still required
+	var mfaEnabled bool
+	err = inits.DB.QueryRowContext(ctx, "SELECT totp_enabled FROM users WHERE username = $1", user.Username).Scan(&mfaEnabled)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "Database error"})
+		return
+	}
+	if mfaEnabled {
+		mfaToken, err := tokens.SignMFAPendingToken(user.Username)
+		if err != nil {
+			ctx.JSON(500, gin.H{"error": "error signing token"})
+			return
+		}
+		audit.Record(ctx, audit.EventMFARequired, user.Username, "oidc")
+		if redirect != "" {
+			// the fragment never reaches a server log
+			ctx.Redirect(http.StatusFound, redirect+"#mfa_token="+url.QueryEscape(mfaToken))
+			return
+		}
+		ctx.JSON(200, gin.H{"data": "Two-factor authentication required", "mfa_required": true, "mfa_token": mfaToken})
+		return
+	}
+
+	session, err := startSession(ctx, user)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "error signing token"})
+		return
+	}
+	audit.Record(ctx, audit.EventLoginSuccess, user.Username, "oidc")
+
+	body := deliverTokens(ctx, authModeCookie, session, gin.H{"data": "Successfully logged in!", "user": user.Username})
+	if redirect != "" {
+		ctx.Redirect(http.StatusFound, redirect)
+		return
+	}
+	ctx.JSON(200, body)
+}
+
+// linkOIDCIdentity links the identity to the account that started the
+// flow. Linking an identity the account already has is a no-op
+func linkOIDCIdentity(ctx *gin.Context, username string, claims *oidc.Claims) error {
+	var owner string
+	err := inits.DB.QueryRowContext(ctx,
+		`INSERT INTO user_identities (issuer, subject, username) VALUES ($1, $2, $3)
+			ON CONFLICT (issuer, subject) DO UPDATE SET issuer = EXCLUDED.issuer
+			RETURNING username`,
+		inits.OIDC.Issuer, claims.Subject, username).Scan(&owner)
+	if err != nil {
+		return err
+	}
+	if owner != username {
+		return errIdentityTaken
+	}
+	return nil
+}
+
+// oidcUser returns the local user linked to an OIDC identity. Unknown
+// identities get a new viewer account with an unusable password when signup
+// is open. They are never matched to an existing account by email, since
+// local emails are not verified; users link identities themselves with
+// OIDCLink instead
+func oidcUser(ctx *gin.Context, claims *oidc.Claims) (models.User, error) {
+	var user models.User
+	issuer := inits.OIDC.Issuer
+
+	err := inits.DB.QueryRowContext(ctx,
+		`SELECT u.Name, u.Username, u.Role
+			FROM user_identities i
+			JOIN users u ON i.username = u.username
+			WHERE i.issuer = $1 AND i.subject = $2`,
+		issuer, claims.Subject).Scan(&user.Name, &user.Username, &user.Role)
+	if err == nil {
+		return user, nil
+	} else if err != sql.ErrNoRows {
+		return user, err
+	}
+
+	if signupMode() != signupOpen {
+		return user, errSignupDisabled
+	}
+
+	tx, err := inits.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return user, err
+	}
+	defer tx.Rollback()
+	if err := lockSignups(ctx, tx); err != nil {
+		return user, err
+	}
+
+	user = models.User{Name: claims.Name, Role: rbac.RoleViewer}
+	base := oidcUsername(claims)
+	for i := 0; i < 100; i++ {
+		candidate := base
+		if i > 0 {
+			candidate = fmt.Sprintf("%s%d", base, i+1)
+		}
+		result, err := tx.ExecContext(ctx,
+			`INSERT INTO users (Name, Username, Password, Email, Role)
+				VALUES ($1, $2, $3, NULLIF($4, ''), $5)
+				ON CONFLICT (username) DO NOTHING`,
+			user.Name, candidate, unusablePassword, claims.Email, user.Role)
+		if err != nil {
+			return user, err
+		}
+		if n, _ := result.RowsAffected(); n == 1 {
+			user.Username = candidate
+			break
+		}
+	}
+	if user.Username == "" {
+		return user, fmt.Errorf("could not find a free username for %q", base)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO user_identities (issuer, subject, username) VALUES ($1, $2, $3)",
+		issuer, claims.Subject, user.Username)
+	if err != nil {
+		return user, err
+	}
+	if err := tx.Commit(); err != nil {
+		return user, err
+	}
+	audit.Record(ctx, audit.EventSignup, user.Username, "oidc")
+	return user, nil
+}
+
+// oidcUsername picks a username for a provisioned account from the claims
+func oidcUsername(claims *oidc.Claims) string {
+	if name := strings.TrimSpace(claims.PreferredUsername); name != "" {
+		return name
+	}
+	if local, _, found := strings.Cut(claims.Email, "@"); found && local != "" {
+		return local
+	}
+	return "oidc-" + claims.Subject
+}
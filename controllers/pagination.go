@@ -0,0 +1,307 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reviser/internal/inits"
+	"reviser/internal/judge"
+	"reviser/internal/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// Lists are paginated with opaque keyset cursors holding the sort key of
+// the row a page ends at, so pages do not shift when rows are inserted
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// keysetColumn is one column of the order a list is paginated by. expr
+// must never be NULL and cast is the Postgres type of its cursor value
+type keysetColumn struct {
+	expr string
+	cast string
+	desc bool
+}
+
+// submissionOrder lists submissions newest first
+var submissionOrder = []keysetColumn{
+	{expr: "s.submitted_at", cast: "timestamptz", desc: true},
+	{expr: "s.submission_id", cast: "bigint", desc: true},
+}
+
+// pageCursor points just past the last row of a page, in the direction
+// the page was read. Keys holds that row's sort key as text
+type pageCursor struct {
+	Keys []string `json:"k"`
+	Prev bool     `json:"p,omitempty"`
+}
+
+func encodeCursor(c pageCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(value string) (pageCursor, error) {
+	var c pageCursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(data, &c)
+	return c, err
+}
+
+// validate checks the cursor holds one well formed key per column, so a
+// forged cursor is rejected rather than failing the query
+func (c pageCursor) validate(columns []keysetColumn) error {
+	if len(c.Keys) != len(columns) {
+		return errors.New("cursor does not match the list order")
+	}
+	for i, column := range columns {
+		var err error
+		switch column.cast {
+		case "timestamptz":
+			_, err = time.Parse(time.RFC3339Nano, c.Keys[i])
+		case "bigint":
+			_, err = strconv.ParseInt(c.Keys[i], 10, 64)
+		case "real":
+			_, err = strconv.ParseFloat(c.Keys[i], 64)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// timeKey formats a timestamp sort key for a pageCursor
+func timeKey(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// pageRequest holds the cursor, limit and count query parameters.
+// Offset only serves the deprecated from/to parameters of /pages
+type pageRequest struct {
+	Columns []keysetColumn
+	Cursor  *pageCursor
+	Limit   int
+	Offset  int
+	Count   bool
+}
+
+// parsePageRequest reads the page of a list ordered by columns, with
+// limit defaulting to defaultLimit and capped at maxLimit
+func parsePageRequest(ctx *gin.Context, columns []keysetColumn, defaultLimit, maxLimit int) (pageRequest, bool) {
+	page := pageRequest{Columns: columns, Limit: defaultLimit, Count: ctx.Query("count") == "true"}
+	if value := ctx.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			ctx.JSON(400, gin.H{"error": "invalid 'limit' query parameter"})
+			return page, false
+		}
+		page.Limit = min(limit, maxLimit)
+	}
+	if value := ctx.Query("cursor"); value != "" {
+		cursor, err := decodeCursor(value)
+		if err == nil {
+			err = cursor.validate(columns)
+		}
+		if err != nil {
+			ctx.JSON(400, gin.H{"error": "invalid 'cursor' query parameter"})
+			return page, false
+		}
+		page.Cursor = &cursor
+	}
+	return page, true
+}
+
+// where returns the condition keeping the rows after the cursor, binding
+// the cursor from $next on. Without a cursor every row is kept
+func (p pageRequest) where(next int) (string, []interface{}) {
+	if p.Cursor == nil {
+		return "TRUE", nil
+	}
+	args := make([]interface{}, len(p.Columns))
+	params := make([]string, len(p.Columns))
+	exprs := make([]string, len(p.Columns))
+	uniform := true
+	for i, column := range p.Columns {
+		args[i] = p.Cursor.Keys[i]
+		params[i] = fmt.Sprintf("$%d::%s", next+i, column.cast)
+		exprs[i] = column.expr
+		uniform = uniform && column.desc == p.Columns[0].desc
+	}
+	after := func(i int) string {
+		if p.Columns[i].desc != p.Cursor.Prev {
+			return "<"
+		}
+		return ">"
+	}
+	if uniform {
+		// a row comparison can use an index on the columns
+		return fmt.Sprintf("(%s) %s (%s)", strings.Join(exprs, ", "), after(0), strings.Join(params, ", ")), args
+	}
+	// mixed directions: a > x OR (a = x AND (b < y OR (b = y AND ...)))
+	clause := ""
+	for i := len(p.Columns) - 1; i >= 0; i-- {
+		term := fmt.Sprintf("%s %s %s", exprs[i], after(i), params[i])
+		if clause != "" {
+			term = fmt.Sprintf("%s OR (%s = %s AND (%s))", term, exprs[i], params[i], clause)
+		}
+		clause = term
+	}
+	return "(" + clause + ")", args
+}
+
+// order returns the ORDER BY reading the page, reversed for a backward
+// page
+func (p pageRequest) order() string {
+	terms := make([]string, len(p.Columns))
+	for i, column := range p.Columns {
+		dir := "ASC"
+		if column.desc != (p.Cursor != nil && p.Cursor.Prev) {
+			dir = "DESC"
+		}
+		terms[i] = column.expr + " " + dir
+	}
+	return "ORDER BY " + strings.Join(terms, ", ")
+}
+
+// orderBy returns order followed by a LIMIT bound to $next that reads
+// one extra row, telling whether another page follows
+func (p pageRequest) orderBy(next int) (string, []interface{}) {
+	order := p.order() + fmt.Sprintf(" LIMIT $%d", next)
+	args := []interface{}{p.Limit + 1}
+	if p.Cursor == nil && p.Offset > 0 {
+		order += fmt.Sprintf(" OFFSET $%d", next+1)
+		args = append(args, p.Offset)
+	}
+	return order, args
+}
+
+// finishPage trims the extra row read by orderBy, puts a backward page
+// back in list order and returns the rows with the response fields
+// limit, next_cursor and prev_cursor. key returns the sort key of a row
+func finishPage[T any](p pageRequest, rows []T, key func(T) []string) ([]T, gin.H) {
+	more := len(rows) > p.Limit
+	if more {
+		rows = rows[:p.Limit]
+	}
+	hasNext, hasPrev := more, p.Cursor != nil || p.Offset > 0
+	if p.Cursor != nil && p.Cursor.Prev {
+		// backward pages are read in reverse
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+		hasNext, hasPrev = true, more
+	}
+
+	response := gin.H{"limit": p.Limit, "next_cursor": nil, "prev_cursor": nil}
+	if len(rows) > 0 {
+		if hasNext {
+			response["next_cursor"] = encodeCursor(pageCursor{Keys: key(rows[len(rows)-1])})
+		}
+		if hasPrev {
+			response["prev_cursor"] = encodeCursor(pageCursor{Keys: key(rows[0]), Prev: true})
+		}
+	}
+	return rows, response
+}
+
+// submissionColumns selects a models.Leetcode_submissions from the
+// leetcode_submissions table aliased as s
+const submissionColumns = `s.submission_id, s.username, s.question_slug, s.code, s.submitted_at,
+	s.language, s.status, s.runtime_ms, s.memory_mb, s.runtime_percentile, s.memory_percentile`
+
+func submissionFields(s *models.Leetcode_submissions) []interface{} {
+	return []interface{}{
+		&s.Submission_ID, &s.Username, &s.Question_Slug, &s.Code, &s.Submitted_At,
+		&s.Language, &s.Status, &s.Runtime_Ms, &s.Memory_Mb, &s.Runtime_Percentile, &s.Memory_Percentile,
+	}
+}
+
+// listSubmissions responds with one page of the submissions matching
+// where, joined with their question. where may reference args as $1..$n.
+// Every list also accepts comma separated language and status filters
+func listSubmissions(ctx *gin.Context, page pageRequest, where string, args ...interface{}) {
+	if languages := splitList(ctx.Query("language")); len(languages) > 0 {
+		for i := range languages {
+			languages[i] = judge.NormalizeLanguage(languages[i])
+		}
+		args = append(args, pq.Array(languages))
+		where += fmt.Sprintf(" AND s.language = ANY($%d::text[])", len(args))
+	}
+	if statuses := splitList(ctx.Query("status")); len(statuses) > 0 {
+		for i := range statuses {
+			status, ok := judge.NormalizeStatus(statuses[i])
+			if !ok {
+				ctx.JSON(400, gin.H{"error": "invalid 'status' query parameter", "status": statuses[i]})
+				return
+			}
+			statuses[i] = status
+		}
+		args = append(args, pq.Array(statuses))
+		where += fmt.Sprintf(" AND s.status = ANY($%d::text[])", len(args))
+	}
+
+	base := `
+		FROM leetcode_submissions s
+		JOIN leetcode_questions q ON s.question_slug = q.slug
+		WHERE ` + where
+	after, afterArgs := page.where(len(args) + 1)
+	pageArgs := append(append([]interface{}{}, args...), afterArgs...)
+	order, orderArgs := page.orderBy(len(pageArgs) + 1)
+	query := `
+		SELECT ` + submissionColumns + `, q.title, q.description` + base + `
+			AND ` + after + `
+		` + order
+	rows, err := inits.DB.QueryContext(ctx, query, append(pageArgs, orderArgs...)...)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	type submissionRow struct {
+		Submission models.Leetcode_submissions
+		Question   models.Leetcode_Questions
+	}
+	var results []submissionRow
+	for rows.Next() {
+		var s submissionRow
+		if err := rows.Scan(append(submissionFields(&s.Submission), &s.Question.Title, &s.Question.Description)...); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan row"})
+			return
+		}
+		results = append(results, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Row iteration error"})
+		return
+	}
+
+	results, response := finishPage(page, results, func(s submissionRow) []string {
+		return []string{timeKey(s.Submission.Submitted_At), strconv.FormatUint(uint64(s.Submission.Submission_ID), 10)}
+	})
+	response["submissions"] = results
+
+	if page.Count {
+		var total int64
+		if err := inits.DB.QueryRowContext(ctx, "SELECT COUNT(*)"+base, args...).Scan(&total); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		response["total"] = total
+	}
+	ctx.JSON(200, response)
+}
@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"reflect"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	submitted := time.Date(2024, 3, 1, 12, 30, 0, 123456000, time.FixedZone("", 3600))
+	for _, c := range []pageCursor{
+		{Keys: []string{timeKey(submitted), "42"}},
+		{Keys: []string{"0.0607927", "two-sum", "0"}, Prev: true},
+		{Keys: []string{"slug with spaces/and+symbols"}},
+	} {
+		encoded := encodeCursor(c)
+		decoded, err := decodeCursor(encoded)
+		if err != nil {
+			t.Fatalf("decodeCursor(%q): %v", encoded, err)
+		}
+		if !reflect.DeepEqual(decoded, c) {
+			t.Errorf("round trip of %+v = %+v", c, decoded)
+		}
+	}
+	if got := timeKey(submitted); got != "2024-03-01T11:30:00.123456Z" {
+		t.Errorf("timeKey = %q", got)
+	}
+}
+
+func TestDecodeCursorErrors(t *testing.T) {
+	for _, value := range []string{"not base64!", "bm90IGpzb24", "eyJrIjoxfQ"} {
+		if _, err := decodeCursor(value); err == nil {
+			t.Errorf("decodeCursor(%q) succeeded", value)
+		}
+	}
+}
+
+func TestCursorValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		keys  []string
+		valid bool
+	}{
+		{"valid", []string{"2024-03-01T12:00:00Z", "7"}, true},
+		{"missing key", []string{"2024-03-01T12:00:00Z"}, false},
+		{"extra key", []string{"2024-03-01T12:00:00Z", "7", "8"}, false},
+		{"bad time", []string{"yesterday", "7"}, false},
+		{"bad id", []string{"2024-03-01T12:00:00Z", "7; DROP TABLE users"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := pageCursor{Keys: tt.keys}.validate(submissionOrder)
+			if (err == nil) != tt.valid {
+				t.Errorf("validate(%q) = %v, want valid %v", tt.keys, err, tt.valid)
+			}
+		})
+	}
+	if err := (pageCursor{Keys: []string{"0.5", "a", "0"}}).validate(searchOrder); err != nil {
+		t.Errorf("search cursor rejected: %v", err)
+	}
+	if err := (pageCursor{Keys: []string{"high", "a", "0"}}).validate(searchOrder); err == nil {
+		t.Error("search cursor with a bad rank accepted")
+	}
+}
+
+func TestPageWhereAndOrder(t *testing.T) {
+	mixed := []keysetColumn{
+		{expr: "rank", cast: "real", desc: true},
+		{expr: "slug", cast: "text"},
+	}
+	tests := []struct {
+		name      string
+		page      pageRequest
+		wantWhere string
+		wantOrder string
+	}{
+		{
+			name:      "first page",
+			page:      pageRequest{Columns: submissionOrder, Limit: 10},
+			wantWhere: "TRUE",
+			wantOrder: "ORDER BY s.submitted_at DESC, s.submission_id DESC LIMIT $3",
+		},
+		{
+			name:      "next page",
+			page:      pageRequest{Columns: submissionOrder, Limit: 10, Cursor: &pageCursor{Keys: []string{"t", "1"}}},
+			wantWhere: "(s.submitted_at, s.submission_id) < ($3::timestamptz, $4::bigint)",
+			wantOrder: "ORDER BY s.submitted_at DESC, s.submission_id DESC LIMIT $5",
+		},
+		{
+			name:      "previous page",
+			page:      pageRequest{Columns: submissionOrder, Limit: 10, Cursor: &pageCursor{Keys: []string{"t", "1"}, Prev: true}},
+			wantWhere: "(s.submitted_at, s.submission_id) > ($3::timestamptz, $4::bigint)",
+			wantOrder: "ORDER BY s.submitted_at ASC, s.submission_id ASC LIMIT $5",
+		},
+		{
+			name:      "mixed directions",
+			page:      pageRequest{Columns: mixed, Limit: 10, Cursor: &pageCursor{Keys: []string{"0.5", "a"}}},
+			wantWhere: "(rank < $3::real OR (rank = $3::real AND (slug > $4::text)))",
+			wantOrder: "ORDER BY rank DESC, slug ASC LIMIT $5",
+		},
+		{
+			name:      "mixed directions backwards",
+			page:      pageRequest{Columns: mixed, Limit: 10, Cursor: &pageCursor{Keys: []string{"0.5", "a"}, Prev: true}},
+			wantWhere: "(rank > $3::real OR (rank = $3::real AND (slug < $4::text)))",
+			wantOrder: "ORDER BY rank ASC, slug DESC LIMIT $5",
+		},
+		{
+			name:      "deprecated offset",
+			page:      pageRequest{Columns: submissionOrder, Limit: 10, Offset: 20},
+			wantWhere: "TRUE",
+			wantOrder: "ORDER BY s.submitted_at DESC, s.submission_id DESC LIMIT $3 OFFSET $4",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			where, args := tt.page.where(3)
+			if where != tt.wantWhere {
+				t.Errorf("where = %q, want %q", where, tt.wantWhere)
+			}
+			if tt.page.Cursor != nil && !reflect.DeepEqual(args, []interface{}{tt.page.Cursor.Keys[0], tt.page.Cursor.Keys[1]}) {
+				t.Errorf("where args = %v", args)
+			}
+			order, orderArgs := tt.page.orderBy(3 + len(args))
+			if order != tt.wantOrder {
+				t.Errorf("orderBy = %q, want %q", order, tt.wantOrder)
+			}
+			if orderArgs[0] != tt.page.Limit+1 {
+				t.Errorf("limit arg = %v, want %d", orderArgs[0], tt.page.Limit+1)
+			}
+		})
+	}
+}
+
+func TestFinishPage(t *testing.T) {
+	key := func(n int) []string { return []string{strconv.Itoa(n)} }
+	tests := []struct {
+		name     string
+		page     pageRequest
+		rows     []int
+		want     []int
+		wantNext []string
+		wantPrev []string
+	}{
+		{"only page", pageRequest{Limit: 3}, []int{9, 8}, []int{9, 8}, nil, nil},
+		{"first of several", pageRequest{Limit: 2}, []int{9, 8, 7}, []int{9, 8}, []string{"8"}, nil},
+		{"middle page", pageRequest{Limit: 2, Cursor: &pageCursor{}}, []int{7, 6, 5}, []int{7, 6}, []string{"6"}, []string{"7"}},
+		{"last page", pageRequest{Limit: 2, Cursor: &pageCursor{}}, []int{5}, []int{5}, nil, []string{"5"}},
+		// backward pages are read in reverse and restored
+		{"backward page", pageRequest{Limit: 2, Cursor: &pageCursor{Prev: true}}, []int{8, 9, 10}, []int{9, 8}, []string{"8"}, []string{"9"}},
+		{"backward to the start", pageRequest{Limit: 2, Cursor: &pageCursor{Prev: true}}, []int{8, 9}, []int{9, 8}, []string{"8"}, nil},
+		{"deprecated offset", pageRequest{Limit: 2, Offset: 4}, []int{5, 4}, []int{5, 4}, nil, []string{"5"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, response := finishPage(tt.page, tt.rows, key)
+			if !slices.Equal(rows, tt.want) {
+				t.Errorf("rows = %v, want %v", rows, tt.want)
+			}
+			if response["limit"] != tt.page.Limit {
+				t.Errorf("limit = %v", response["limit"])
+			}
+			checkCursor(t, "next_cursor", response["next_cursor"], tt.wantNext, false)
+			checkCursor(t, "prev_cursor", response["prev_cursor"], tt.wantPrev, true)
+		})
+	}
+}
+
+func checkCursor(t *testing.T, name string, value interface{}, want []string, prev bool) {
+	t.Helper()
+	if want == nil {
+		if value != nil {
+			t.Errorf("%s = %v, want none", name, value)
+		}
+		return
+	}
+	encoded, ok := value.(string)
+	if !ok {
+		t.Fatalf("%s = %v, want a cursor", name, value)
+	}
+	c, err := decodeCursor(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(c.Keys, want) || c.Prev != prev {
+		t.Errorf("%s = %+v, want keys %v prev %v", name, c, want, prev)
+	}
+}
@@ -0,0 +1,265 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"reviser/internal/audit"
+	"reviser/internal/inits"
+	"reviser/internal/notify"
+	"reviser/internal/password"
+	"reviser/internal/tokens"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const passwordResetTTL = time.Hour
+
+// ChangePassword replaces the current user's password after re-verifying
+// the old one, and revokes every other session of the user. Accounts that
+// sign in with OIDC have no password to change and get 409
+func ChangePassword(ctx *gin.Context) {
+	user, ok := currentUser(ctx)
+	if !ok {
+		ctx.JSON(500, gin.H{"error": "User not found in context"})
+		return
+	}
+
+	var body struct {
+		Old_Password string
+		New_Password string
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+		return
+	}
+
+	if abortIfLocked(ctx, user.Username, "password change") {
+		return
+	}
+
+	var current string
+	err := inits.DB.QueryRowContext(ctx, "SELECT Password FROM users WHERE username = $1", user.Username).Scan(&current)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	// no old password can match, and guessing one must not lock the account
+	if current == unusablePassword {
+		ctx.JSON(http.StatusConflict, gin.H{"error": "Account has no password", "message": "This account signs in with single sign-on"})
+		return
+	}
+	// a stolen session must not be able to guess the password any faster than Login
+	if bcrypt.CompareHashAndPassword([]byte(current), []byte(body.Old_Password)) != nil {
+		if err := recordCredentialFailure(ctx, user.Username); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		audit.Record(ctx, audit.EventPasswordChange, user.Username, "failure: invalid old password")
+		ctx.JSON(401, gin.H{"error": "Invalid credentials"})
+		return
+	}
+	if err := clearLoginFailures(ctx, usernameLockKey(user.Username)); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if err := password.Validate(body.New_Password, user.Username); err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid password", "message": err.Error()})
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(body.New_Password), 10)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "Error Trying to generate hash!"})
+		return
+	}
+
+	tx, err := inits.DB.BeginTx(ctx, nil)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET Password = $1 WHERE username = $2", string(hash), user.Username); err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to update password", "details": err.Error()})
+		return
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE sessions SET revoked_at = now()
+			WHERE username = $1 AND session_id <> $2 AND revoked_at IS NULL`,
+		user.Username, ctx.GetString("session_id")); err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to revoke sessions", "details": err.Error()})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to update password", "details": err.Error()})
+		return
+	}
+
+	audit.Record(ctx, audit.EventPasswordChange, user.Username, "")
+	ctx.JSON(200, gin.H{"status": "Password changed successfully"})
+}
+
+// Password reset requests are limited per client IP, answered with 429, and
+// per email, silently, so the limit does not reveal which accounts exist
+const (
+	resetIPLimit     = 10
+	resetEmailLimit  = 3
+	resetLimitWindow = time.Hour
+	resetSendTimeout = 30 * time.Second
+)
+
+// ForgotPassword sends a password reset token to the email of the user.
+// It answers the same way whether or not the user exists, and the token is
+// created and delivered in the background so the response time does not
+// depend on the account either
+func ForgotPassword(ctx *gin.Context) {
+	var body struct {
+		Username string
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+		return
+	}
+
+	wait, err := rateLimited(ctx, "reset:ip:"+ctx.ClientIP(), resetIPLimit, resetLimitWindow)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if wait > 0 {
+		abortRateLimited(ctx, wait)
+		return
+	}
+
+	var email string
+	err = inits.DB.QueryRowContext(ctx,
+		"SELECT COALESCE(email, '') FROM users WHERE username = $1", body.Username).Scan(&email)
+	if err != nil && err != sql.ErrNoRows {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if email != "" {
+		go sendPasswordReset(body.Username, email)
+	}
+
+	audit.Record(ctx, audit.EventResetRequested, body.Username, "")
+	ctx.JSON(200, gin.H{"status": "If the account exists, a reset link has been sent"})
+}
+
+// sendPasswordReset creates a reset token for the user and delivers it.
+// It runs after the request has been answered, so failures are only logged
+func sendPasswordReset(username string, email string) {
+	ctx, cancel := context.WithTimeout(context.Background(), resetSendTimeout)
+	defer cancel()
+
+	wait, err := rateLimited(ctx, "reset:email:"+strings.ToLower(email), resetEmailLimit, resetLimitWindow)
+	if err != nil {
+		log.Printf("Failed to rate limit password reset of %s: %v", username, err)
+		return
+	}
+	if wait > 0 {
+		log.Printf("Password reset of %s rate limited", username)
+		return
+	}
+
+	token, hash, err := tokens.NewOpaqueToken()
+	if err != nil {
+		log.Printf("Failed to generate reset token for %s: %v", username, err)
+		return
+	}
+	_, err = inits.DB.ExecContext(ctx,
+		"INSERT INTO password_resets (token_hash, username, expires_at) VALUES ($1, $2, $3)",
+		hash, username, time.Now().Add(passwordResetTTL))
+	if err != nil {
+		log.Printf("Failed to store reset token for %s: %v", username, err)
+		return
+	}
+
+	msg := notify.Message{
+		To:      email,
+		Subject: "Reset your Reviser password",
+		Body:    "Use this token to reset your password within the next hour:\r\n\r\n" + token,
+	}
+	if resetURL := os.Getenv("RESET_URL"); resetURL != "" {
+		msg.Body = "Open this link to reset your password within the next hour:\r\n\r\n" +
+			resetURL + "?token=" + url.QueryEscape(token)
+	}
+	if err := inits.Notifier.Send(ctx, msg); err != nil {
+		log.Printf("Failed to send password reset to %s: %v", username, err)
+	}
+}
+
+// ResetPassword sets a new password using a reset token, revokes every
+// session of the user and clears their failed login counter
+func ResetPassword(ctx *gin.Context) {
+	var body struct {
+		Token        string
+		New_Password string
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+		return
+	}
+
+	tx, err := inits.DB.BeginTx(ctx, nil)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	var username string
+	err = tx.QueryRowContext(ctx,
+		`SELECT username FROM password_resets
+			WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+			FOR UPDATE`,
+		tokens.HashOpaqueToken(body.Token)).Scan(&username)
+	if err == sql.ErrNoRows {
+		ctx.JSON(400, gin.H{"error": "Invalid or expired reset token"})
+		return
+	} else if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if err := password.Validate(body.New_Password, username); err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid password", "message": err.Error()})
+		return
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(body.New_Password), 10)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "Error Trying to generate hash!"})
+		return
+	}
+
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		{"UPDATE users SET Password = $1 WHERE username = $2", []interface{}{string(hash), username}},
+		{"UPDATE password_resets SET used_at = now() WHERE username = $1 AND used_at IS NULL", []interface{}{username}},
+		{"UPDATE sessions SET revoked_at = now() WHERE username = $1 AND revoked_at IS NULL", []interface{}{username}},
+		{"DELETE FROM login_failures WHERE key = $1", []interface{}{usernameLockKey(username)}},
+	}
+	for _, stmt := range statements {
+		if _, err := tx.ExecContext(ctx, stmt.query, stmt.args...); err != nil {
+			ctx.JSON(500, gin.H{"error": "Failed to reset password", "details": err.Error()})
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to reset password", "details": err.Error()})
+		return
+	}
+
+	audit.Record(ctx, audit.EventPasswordReset, username, "")
+	ctx.JSON(200, gin.H{"status": "Password reset successfully"})
+}
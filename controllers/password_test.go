@@ -0,0 +1,26 @@
+package controllers
+
+import (
+	"database/sql/driver"
+	"reviser/internal/models"
+	"testing"
+)
+
+func TestChangePasswordWithoutPassword(t *testing.T) {
+	db := useFakeDB(t, notLocked, fakeResult{
+		match:   "SELECT Password FROM users",
+		columns: []string{"password"},
+		rows:    [][]driver.Value{{unusablePassword}},
+	})
+	body := map[string]string{"Old_Password": unusablePassword, "New_Password": "a much longer passphrase"}
+	status, response := serve(t, ChangePassword, models.User{Username: "oidc-user"}, "session-1", "POST", "/auth/password", body)
+	if status != 409 {
+		t.Fatalf("status = %d, want 409: %v", status, response)
+	}
+	if db.ran("INSERT INTO login_failures") {
+		t.Error("recorded a credential failure")
+	}
+	if db.ran("UPDATE users SET Password") {
+		t.Error("set a password")
+	}
+}
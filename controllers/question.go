@@ -4,15 +4,24 @@ import (
 	"database/sql"
 	"net/http"
 	"reviser/internal/inits"
+	"reviser/internal/judge"
 	"reviser/internal/models"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
 )
 
 // FetchTagsBySlug retrieves tags by slug from the database
 func FetchTagsBySlug(ctx *gin.Context) {
+	user, ok := currentUser(ctx)
+	if !ok {
+		ctx.JSON(500, gin.H{"error": "User not found in context"})
+		return
+	}
+
 	slug := ctx.Query("slug")
 	if slug == "" {
 		ctx.JSON(400, gin.H{"error": "Slug is required"})
@@ -20,8 +29,8 @@ func FetchTagsBySlug(ctx *gin.Context) {
 	}
 	// Fetch the tags from the database using the slug
 	var tags models.Question_Tags
-	query := "SELECT tags FROM question_tags WHERE slug = $1"
-	err := inits.DB.QueryRowContext(ctx, query, slug).Scan(&tags.Tags)
+	query := "SELECT " + tagNamesSQL("qt.slug", "qt.username") + " FROM question_tags qt WHERE qt.username = $1 AND qt.slug = $2"
+	err := inits.DB.QueryRowContext(ctx, query, user.Username, slug).Scan(&tags.Tags)
 	if err == sql.ErrNoRows {
 		ctx.JSON(http.StatusNotFound, gin.H{"error": "Tags not found"})
 		return
@@ -34,9 +43,15 @@ func FetchTagsBySlug(ctx *gin.Context) {
 
 // FetchQuestionsCount retrieves the count of questions from the database
 func FetchQuestionsCount(ctx *gin.Context) {
+	user, ok := currentUser(ctx)
+	if !ok {
+		ctx.JSON(500, gin.H{"error": "User not found in context"})
+		return
+	}
+
 	var count int64
-	query := "SELECT COUNT(*) FROM question_tags"
-	err := inits.DB.QueryRowContext(ctx, query).Scan(&count)
+	query := "SELECT COUNT(*) FROM question_tags WHERE username = $1"
+	err := inits.DB.QueryRowContext(ctx, query, user.Username).Scan(&count)
 	if err != nil {
 		ctx.JSON(500, gin.H{"error": "Database error"})
 		return
@@ -44,10 +59,116 @@ func FetchQuestionsCount(ctx *gin.Context) {
 	ctx.JSON(200, gin.H{"count": count})
 }
 
-// FetchAllQuestions retrieves all questions from the database
+// questionOrder lists questions by slug
+var questionOrder = []keysetColumn{{expr: "q.slug", cast: "text"}}
+
+// questionColumns selects a models.Leetcode_Questions from the
+// leetcode_questions table aliased as q
+const questionColumns = `q.slug, q.title, q.description, q.difficulty, q.platform_id, q.url,
+	q.is_premium, q.topics, q.hints, q.similar_slugs, q.acceptance_rate`
+
+func questionFields(q *models.Leetcode_Questions) []interface{} {
+	return []interface{}{
+		&q.Slug, &q.Title, &q.Description, &q.Difficulty, &q.Platform_ID, &q.URL,
+		&q.Is_Premium, &q.Topics, &q.Hints, &q.Similar_Slugs, &q.Acceptance_Rate,
+	}
+}
+
+// normalizeDifficulty maps a difficulty in any case to Easy, Medium or
+// Hard. The empty difficulty stands for unknown
+func normalizeDifficulty(difficulty string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(difficulty)) {
+	case "":
+		return "", true
+	case "easy":
+		return "Easy", true
+	case "medium":
+		return "Medium", true
+	case "hard":
+		return "Hard", true
+	}
+	return "", false
+}
+
+// FetchAllQuestions retrieves all questions the current user has submitted.
+// They can be filtered by difficulty, official topics, premium flag, the
+// user's own tags (tags and match) and not_revised_days, which keeps
+// questions without a submission or review in that many days
 func FetchAllQuestions(ctx *gin.Context) {
-	query := "SELECT slug, title, description FROM leetcode_questions"
-	rows, err := inits.DB.QueryContext(ctx, query)
+	user, ok := currentUser(ctx)
+	if !ok {
+		ctx.JSON(500, gin.H{"error": "User not found in context"})
+		return
+	}
+
+	difficulties := []string{}
+	for _, value := range splitList(ctx.Query("difficulty")) {
+		difficulty, ok := normalizeDifficulty(value)
+		if !ok || difficulty == "" {
+			ctx.JSON(400, gin.H{"error": "invalid 'difficulty' query parameter, expected Easy, Medium or Hard"})
+			return
+		}
+		difficulties = append(difficulties, difficulty)
+	}
+	topics := normalizeTagNames(splitList(ctx.Query("topics")))
+	var premium *bool
+	if value := ctx.Query("premium"); value != "" {
+		p, err := strconv.ParseBool(value)
+		if err != nil {
+			ctx.JSON(400, gin.H{"error": "invalid 'premium' query parameter"})
+			return
+		}
+		premium = &p
+	}
+	var notRevisedDays *int
+	if value := ctx.Query("not_revised_days"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 0 {
+			ctx.JSON(400, gin.H{"error": "invalid 'not_revised_days' query parameter"})
+			return
+		}
+		notRevisedDays = &days
+	}
+	filter, ok := parseTagFilter(ctx)
+	if !ok {
+		return
+	}
+	page, ok := parsePageRequest(ctx, questionOrder, defaultPageSize, maxPageSize)
+	if !ok {
+		return
+	}
+	after, afterArgs := page.where(7)
+	order, orderArgs := page.orderBy(7 + len(afterArgs))
+
+	query := `
+		SELECT ` + questionColumns + `
+		FROM leetcode_questions q
+		WHERE EXISTS (
+			SELECT 1 FROM leetcode_submissions s
+			WHERE s.question_slug = q.slug AND s.username = $1
+		)
+			AND (cardinality($2::text[]) = 0 OR q.difficulty = ANY($2::text[]))
+			AND (cardinality($3::text[]) = 0 OR EXISTS (
+				SELECT 1 FROM jsonb_array_elements_text(q.topics) AS topic
+				WHERE lower(topic) = ANY($3::text[])
+			))
+			AND ($4::boolean IS NULL OR q.is_premium = $4)
+			AND ($5::integer IS NULL OR NOT EXISTS (
+				SELECT 1 FROM leetcode_submissions s
+				WHERE s.question_slug = q.slug AND s.username = $1
+					AND s.submitted_at >= now() - make_interval(days => $5::integer)
+				UNION ALL
+				SELECT 1 FROM review_log r
+				WHERE r.question_slug = q.slug AND r.username = $1
+					AND r.reviewed_at >= now() - make_interval(days => $5::integer)
+			))
+			AND ` + filter.clause("q.slug", 1, 6) + `
+			AND ` + after + `
+		` + order
+	args := append([]interface{}{
+		user.Username, pq.Array(difficulties), pq.Array(topics), premium, notRevisedDays,
+	}, filter.args()...)
+	rows, err := inits.DB.QueryContext(ctx, query, append(append(args, afterArgs...), orderArgs...)...)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
@@ -57,7 +178,7 @@ func FetchAllQuestions(ctx *gin.Context) {
 	var questions []models.Leetcode_Questions
 	for rows.Next() {
 		var q models.Leetcode_Questions
-		if err := rows.Scan(&q.Slug, &q.Title, &q.Description); err != nil {
+		if err := rows.Scan(questionFields(&q)...); err != nil {
 			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan row"})
 			return
 		}
@@ -69,208 +190,222 @@ func FetchAllQuestions(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(200, gin.H{"questions": questions})
+	questions, response := finishPage(page, questions, func(q models.Leetcode_Questions) []string {
+		return []string{q.Slug}
+	})
+	response["questions"] = questions
+	ctx.JSON(200, response)
 }
 
 // FetchSubmissionsBySlug retrieves submissions by slug from the database
 // with joined question data
 func FetchSubmissionsBySlug(ctx *gin.Context) {
+	user, ok := currentUser(ctx)
+	if !ok {
+		ctx.JSON(500, gin.H{"error": "User not found in context"})
+		return
+	}
+
 	slug := ctx.Param("slug")
 	if slug == "" {
 		ctx.JSON(400, gin.H{"error": "Slug is required"})
 		return
 	}
 
-	query := `
-		SELECT 
-			s.submission_id, s.question_slug, s.code, s.submitted_at,
-			q.title, q.description
-		FROM leetcode_submissions s
-		JOIN leetcode_questions q ON s.question_slug = q.slug
-		WHERE s.question_slug = $1
-	`
-	rows, err := inits.DB.QueryContext(ctx, query, slug)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+	page, ok := parsePageRequest(ctx, submissionOrder, defaultPageSize, maxPageSize)
+	if !ok {
 		return
 	}
-	defer rows.Close()
-
-	var results []struct {
-		Submission models.Leetcode_submissions
-		Question   models.Leetcode_Questions
-	}
-	for rows.Next() {
-		var s struct {
-			Submission models.Leetcode_submissions
-			Question   models.Leetcode_Questions
-		}
-		if err := rows.Scan(
-			&s.Submission.Submission_ID,
-			&s.Submission.Question_Slug,
-			&s.Submission.Code,
-			&s.Submission.Submitted_At,
-			&s.Question.Title,
-			&s.Question.Description,
-		); err != nil {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan row"})
-			return
-		}
-		results = append(results, s)
-	}
+	listSubmissions(ctx, page, "s.username = $1 AND s.question_slug = $2", user.Username, slug)
+}
 
-	if err := rows.Err(); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Row iteration error"})
+// FetchSubmissionsForDay retrieves submissions for a specific day in the
+// tz parameter or the user's timezone, with joined question data
+func FetchSubmissionsForDay(ctx *gin.Context) {
+	user, ok := currentUser(ctx)
+	if !ok {
+		ctx.JSON(500, gin.H{"error": "User not found in context"})
 		return
 	}
-	ctx.JSON(200, gin.H{"submissions": results})
-}
 
-// FetchSubmissionsForDay retrieves submissions for a specific day
-// with joined question data
-func FetchSubmissionsForDay(ctx *gin.Context) {
 	date := ctx.Query("date")
 	if date == "" {
 		ctx.JSON(400, gin.H{"error": "Date is required"})
 		return
 	}
-	// Parse the date string into a time.Time object (only date part)
-	const layout = "2006-01-02"
-	startOfDay, error := time.Parse(layout, date)
+	loc, ok := requestLocation(ctx, user)
+	if !ok {
+		return
+	}
+	// the day runs from midnight to the next midnight in the user's zone,
+	// which is not always 24 hours
+	startOfDay, error := time.ParseInLocation(dateLayout, date, loc)
 	if error != nil {
 		ctx.JSON(400, gin.H{"error": "Invalid date format"})
 		return
 	}
-	endOfDay := startOfDay.Add(time.Hour*23 + time.Minute*59 + time.Second*59)
-	query := `
-		SELECT
-		s.submission_id, s.question_slug, s.code, s.submitted_at,
-			q.title, q.description
-		FROM leetcode_submissions s
-		JOIN leetcode_questions q ON s.question_slug = q.slug
-		WHERE s.submitted_at BETWEEN $1 AND $2`
-	rows, err := inits.DB.QueryContext(ctx, query, startOfDay, endOfDay)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+	endOfDay := startOfDay.AddDate(0, 0, 1)
+	filter, ok := parseTagFilter(ctx)
+	if !ok {
 		return
 	}
-	defer rows.Close()
-
-	var results []struct {
-		Submission models.Leetcode_submissions
-		Question   models.Leetcode_Questions
-	}
-	for rows.Next() {
-		var s struct {
-			Submission models.Leetcode_submissions
-			Question   models.Leetcode_Questions
-		}
-		if err := rows.Scan(
-			&s.Submission.Submission_ID,
-			&s.Submission.Question_Slug,
-			&s.Submission.Code,
-			&s.Submission.Submitted_At,
-			&s.Question.Title,
-			&s.Question.Description,
-		); err != nil {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan row"})
-			return
-		}
-		results = append(results, s)
+	page, ok := parsePageRequest(ctx, submissionOrder, defaultPageSize, maxPageSize)
+	if !ok {
+		return
 	}
+	listSubmissions(ctx, page,
+		"s.username = $1 AND s.submitted_at >= $2 AND s.submitted_at < $3 AND "+filter.clause("s.question_slug", 1, 4),
+		append([]interface{}{user.Username, startOfDay, endOfDay}, filter.args()...)...)
+}
 
-	if err := rows.Err(); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Row iteration error"})
+// FetchSubmissionsBetween retrieves submissions in the half-open range
+// [from, to). Dates are read in the tz parameter or the user's timezone
+// and a date in to includes that whole day
+func FetchSubmissionsBetween(ctx *gin.Context) {
+	user, ok := currentUser(ctx)
+	if !ok {
+		ctx.JSON(500, gin.H{"error": "User not found in context"})
 		return
 	}
-	ctx.JSON(200, gin.H{"submissions": results})
-}
 
-// FetchSubmissionsRange retrieves paginated response
-// within from and two in query string parameters
-func FetchSubmissionsRange(ctx *gin.Context) {
-	from, err := strconv.Atoi(ctx.Query("from"))
-	if err != nil {
-		ctx.JSON(400, gin.H{"error": "invalid 'from' query parameter"})
+	loc, ok := requestLocation(ctx, user)
+	if !ok {
 		return
 	}
-	var to int
-	to, err = strconv.Atoi(ctx.Query("to"))
-	if err != nil {
-		ctx.JSON(400, gin.H{"error": "invalid 'to' query parameter"})
+	from, ok := parseRangeBound(ctx, "from", loc, false)
+	if !ok {
+		return
+	}
+	to, ok := parseRangeBound(ctx, "to", loc, true)
+	if !ok {
+		return
+	}
+	filter, ok := parseTagFilter(ctx)
+	if !ok {
+		return
+	}
+	page, ok := parsePageRequest(ctx, submissionOrder, defaultPageSize, maxPageSize)
+	if !ok {
 		return
 	}
+	listSubmissions(ctx, page,
+		`s.username = $1
+			AND ($2::timestamptz IS NULL OR s.submitted_at >= $2)
+			AND ($3::timestamptz IS NULL OR s.submitted_at < $3)
+			AND `+filter.clause("s.question_slug", 1, 4),
+		append([]interface{}{user.Username, from, to}, filter.args()...)...)
+}
 
-	query := `
-		SELECT
-		s.submission_id, s.question_slug, s.code, s.submitted_at,
-			q.title, q.description
-		FROM leetcode_submissions s
-		JOIN leetcode_questions q ON s.question_slug = q.slug
-		ORDER BY s.submitted_at DESC
-		LIMIT $1 OFFSET $2`
-	rows, err := inits.DB.QueryContext(ctx, query, to, from)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+// FetchSubmissionsRange retrieves the user's submissions page by page,
+// following the cursor query parameter. The offset parameters from and
+// to of the previous release are still honoured but deprecated
+func FetchSubmissionsRange(ctx *gin.Context) {
+	user, ok := currentUser(ctx)
+	if !ok {
+		ctx.JSON(500, gin.H{"error": "User not found in context"})
 		return
 	}
-	defer rows.Close()
 
-	var results []struct {
-		Submission models.Leetcode_submissions
-		Question   models.Leetcode_Questions
+	page, ok := parsePageRequest(ctx, submissionOrder, defaultPageSize, maxPageSize)
+	if !ok {
+		return
 	}
-	for rows.Next() {
-		var s struct {
-			Submission models.Leetcode_submissions
-			Question   models.Leetcode_Questions
+	if from, to := ctx.Query("from"), ctx.Query("to"); page.Cursor == nil && (from != "" || to != "") {
+		// from was the offset and to the page size
+		offset, err := strconv.Atoi(from)
+		if err != nil || offset < 0 {
+			ctx.JSON(400, gin.H{"error": "invalid 'from' query parameter"})
+			return
 		}
-		if err := rows.Scan(
-			&s.Submission.Submission_ID,
-			&s.Submission.Question_Slug,
-			&s.Submission.Code,
-			&s.Submission.Submitted_At,
-			&s.Question.Title,
-			&s.Question.Description,
-		); err != nil {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan row"})
+		limit, err := strconv.Atoi(to)
+		if err != nil || limit < 1 {
+			ctx.JSON(400, gin.H{"error": "invalid 'to' query parameter"})
 			return
 		}
-		results = append(results, s)
+		page.Offset, page.Limit = offset, min(limit, maxPageSize)
+		ctx.Header("Deprecation", "true")
+		ctx.Header("Warning", `299 - "from and to are deprecated, use limit and cursor"`)
 	}
-
-	if err := rows.Err(); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Row iteration error"})
+	filter, ok := parseTagFilter(ctx)
+	if !ok {
 		return
 	}
-	ctx.JSON(200, gin.H{"submissions": results})
+	listSubmissions(ctx, page, "s.username = $1 AND "+filter.clause("s.question_slug", 1, 2),
+		append([]interface{}{user.Username}, filter.args()...)...)
 }
 
 // UpsertTags will insert the tags if it doesn;t exists,
 // if exists it will update the tags
 func UpsertTags(ctx *gin.Context) {
+	user, ok := currentUser(ctx)
+	if !ok {
+		ctx.JSON(500, gin.H{"error": "User not found in context"})
+		return
+	}
+
 	var quesTag models.Question_Tags
 	if err := ctx.ShouldBindJSON(&quesTag); err != nil {
 		ctx.JSON(400, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
 		return
 	}
 
-	_, err := inits.DB.Exec(
-		`INSERT INTO question_tags (slug, tags) 
+	quesTag.Username = user.Username
+
+	tx, err := inits.DB.BeginTx(ctx, nil)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO question_tags (username, slug)
 			VALUES ($1, $2)
-			ON CONFLICT (slug)
-			DO UPDATE SET tags = $2`,
-		quesTag.Slug, quesTag.Tags)
+			ON CONFLICT (username, slug) DO NOTHING`,
+		quesTag.Username, quesTag.Slug)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to upsert tags", "details": err.Error()})
+		return
+	}
 
+	// names and aliases resolve to canonical tags, unknown names become new tags
+	tagIDs, err := resolveTags(ctx, tx, quesTag.Tags)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to upsert tags", "details": err.Error()})
+		return
+	}
+	_, err = tx.ExecContext(ctx,
+		"DELETE FROM question_tag_links WHERE username = $1 AND slug = $2 AND NOT (tag_id = ANY($3::bigint[]))",
+		quesTag.Username, quesTag.Slug, pq.Array(tagIDs))
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to upsert tags", "details": err.Error()})
+		return
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO question_tag_links (username, slug, tag_id)
+			SELECT $1, $2, unnest($3::bigint[])
+			ON CONFLICT DO NOTHING`,
+		quesTag.Username, quesTag.Slug, pq.Array(tagIDs))
 	if err != nil {
 		ctx.JSON(500, gin.H{"error": "Failed to upsert tags", "details": err.Error()})
 		return
 	}
+
+	if err := tx.Commit(); err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to upsert tags", "details": err.Error()})
+		return
+	}
 	ctx.JSON(200, gin.H{"status": "Tags updated successfully"})
 }
 
 // DeleteTags will remove the tags entry for particular slug
 func DeleteTags(ctx *gin.Context) {
+	user, ok := currentUser(ctx)
+	if !ok {
+		ctx.JSON(500, gin.H{"error": "User not found in context"})
+		return
+	}
+
 	slug := ctx.Query("slug")
 	if slug == "" {
 		ctx.JSON(400, gin.H{"error": "Slug is required"})
@@ -279,7 +414,7 @@ func DeleteTags(ctx *gin.Context) {
 
 	_, err := inits.DB.Exec(
 		`DELETE FROM question_tags
-		WHERE slug = $1`, slug)
+		WHERE username = $1 AND slug = $2`, user.Username, slug)
 
 	if err != nil {
 		ctx.JSON(500, gin.H{"error": "Failed to delete tags", "details": err.Error()})
@@ -289,20 +424,55 @@ func DeleteTags(ctx *gin.Context) {
 	ctx.JSON(200, gin.H{"status": "Tags deleted successfully"})
 }
 
-// InsertQuestions will upsert the questions into db
+// nullIfNil binds a missing JSON array as NULL rather than JSON null
+func nullIfNil(a models.StringArray) interface{} {
+	if a == nil {
+		return nil
+	}
+	return a
+}
+
+// InsertQuestions will upsert the questions into db. Metadata fields left
+// out of the payload keep their stored value
 func InsertQuestions(ctx *gin.Context) {
-	var question models.Leetcode_Questions
-	if err := ctx.ShouldBindJSON(&question); err != nil {
+	var body struct {
+		models.Leetcode_Questions
+		Is_Premium *bool
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
 		ctx.JSON(400, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
 		return
 	}
+	question := body.Leetcode_Questions
+
+	difficulty, ok := normalizeDifficulty(question.Difficulty)
+	if !ok {
+		ctx.JSON(400, gin.H{"error": "Invalid difficulty, expected Easy, Medium or Hard", "difficulty": question.Difficulty})
+		return
+	}
+	if !validPercentile(question.Acceptance_Rate) {
+		ctx.JSON(400, gin.H{"error": "Acceptance rate must be between 0 and 100"})
+		return
+	}
 
 	_, err := inits.DB.Exec(
-		`INSERT INTO LEETCODE_QUESTIONS (slug, title, description) 
-			VALUES ($1, $2, $3)
+		`INSERT INTO LEETCODE_QUESTIONS (slug, title, description, difficulty, platform_id, url,
+				is_premium, topics, hints, similar_slugs, acceptance_rate)
+			VALUES ($1, $2, $3, $4, $5, $6, COALESCE($7, false),
+				COALESCE($8::jsonb, '[]'), COALESCE($9::jsonb, '[]'), COALESCE($10::jsonb, '[]'), $11)
 			ON CONFLICT (slug)
-			DO UPDATE SET title = $2, description = $3`,
-		question.Slug, question.Title, question.Description)
+			DO UPDATE SET title = $2, description = $3,
+				difficulty = COALESCE(NULLIF($4, ''), LEETCODE_QUESTIONS.difficulty),
+				platform_id = COALESCE($5, LEETCODE_QUESTIONS.platform_id),
+				url = COALESCE(NULLIF($6, ''), LEETCODE_QUESTIONS.url),
+				is_premium = COALESCE($7, LEETCODE_QUESTIONS.is_premium),
+				topics = COALESCE($8, LEETCODE_QUESTIONS.topics),
+				hints = COALESCE($9, LEETCODE_QUESTIONS.hints),
+				similar_slugs = COALESCE($10, LEETCODE_QUESTIONS.similar_slugs),
+				acceptance_rate = COALESCE($11, LEETCODE_QUESTIONS.acceptance_rate)`,
+		question.Slug, question.Title, question.Description, difficulty, question.Platform_ID,
+		strings.TrimSpace(question.URL), body.Is_Premium, nullIfNil(question.Topics), nullIfNil(question.Hints),
+		nullIfNil(question.Similar_Slugs), question.Acceptance_Rate)
 
 	if err != nil {
 		ctx.JSON(500, gin.H{"error": "Failed to upsert Question", "details": err.Error()})
@@ -311,13 +481,52 @@ func InsertQuestions(ctx *gin.Context) {
 	ctx.JSON(200, gin.H{"status": "Question upserted succesfully"})
 }
 
+// validPercentile reports whether an optional percentile is within 0-100
+func validPercentile(p *float64) bool {
+	return p == nil || (*p >= 0 && *p <= 100)
+}
+
+// InsertSubmissions will insert a submission owned by the current user.
+// The language is detected from the code when the scraper leaves it out,
+// the status is required
 func InsertSubmissions(ctx *gin.Context) {
+	user, ok := currentUser(ctx)
+	if !ok {
+		ctx.JSON(500, gin.H{"error": "User not found in context"})
+		return
+	}
+
 	var submission models.Leetcode_submissions
 	if err := ctx.ShouldBindJSON(&submission); err != nil {
 		ctx.JSON(400, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
 		return
 	}
 
+	submission.Username = user.Username
+	// a missing verdict must not be taken for accepted and seed a review
+	if strings.TrimSpace(submission.Status) == "" {
+		ctx.JSON(400, gin.H{"error": "Status is required"})
+		return
+	}
+	status, ok := judge.NormalizeStatus(submission.Status)
+	if !ok {
+		ctx.JSON(400, gin.H{"error": "Invalid status", "status": submission.Status})
+		return
+	}
+	submission.Status = status
+	submission.Language = judge.NormalizeLanguage(submission.Language)
+	if submission.Language == "" {
+		submission.Language = judge.Detect(submission.Code)
+	}
+	if !validPercentile(submission.Runtime_Percentile) || !validPercentile(submission.Memory_Percentile) {
+		ctx.JSON(400, gin.H{"error": "Percentiles must be between 0 and 100"})
+		return
+	}
+	if (submission.Runtime_Ms != nil && *submission.Runtime_Ms < 0) || (submission.Memory_Mb != nil && *submission.Memory_Mb < 0) {
+		ctx.JSON(400, gin.H{"error": "Runtime and memory cannot be negative"})
+		return
+	}
+
 	// Check if the referenced Question exists
 	var question models.Leetcode_Questions
 	row := inits.DB.QueryRow("SELECT slug FROM leetcode_questions WHERE slug = $1 LIMIT 1", submission.Question_Slug)
@@ -331,14 +540,35 @@ func InsertSubmissions(ctx *gin.Context) {
 		return
 	}
 
+	tx, err := inits.DB.BeginTx(ctx, nil)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
 	// Create the submission
-	_, err = inits.DB.Exec(
-		`INSERT INTO LEETCODE_SUBMISSIONS (Submission_ID, Question_Slug, Code,Submitted_At) 
-			VALUES ($1, $2, $3, $4)`,
-		submission.Submission_ID, submission.Question_Slug, submission.Code, submission.Submitted_At)
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO LEETCODE_SUBMISSIONS (Submission_ID, Username, Question_Slug, Code, Submitted_At,
+				Language, Status, Runtime_Ms, Memory_Mb, Runtime_Percentile, Memory_Percentile)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		submission.Submission_ID, submission.Username, submission.Question_Slug, submission.Code, submission.Submitted_At,
+		submission.Language, submission.Status, submission.Runtime_Ms, submission.Memory_Mb,
+		submission.Runtime_Percentile, submission.Memory_Percentile)
 	if err != nil {
 		ctx.JSON(500, gin.H{"error": "Failed to insert submission", "details": err.Error()})
 		return
 	}
+	// only solved questions are due for revision
+	if submission.Status == judge.StatusAccepted {
+		if err := seedReviewSchedule(ctx, tx, submission.Username, submission.Question_Slug, submission.Submitted_At); err != nil {
+			ctx.JSON(500, gin.H{"error": "Failed to seed review schedule", "details": err.Error()})
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to insert submission", "details": err.Error()})
+		return
+	}
 	ctx.JSON(200, gin.H{"status": "Submission inserted successfully"})
 }
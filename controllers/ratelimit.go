@@ -0,0 +1,25 @@
+package controllers
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"reviser/internal/ratelimit"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rateLimited counts a request against key and returns how long the caller
+// has to wait once more than limit requests were made in the current window
+func rateLimited(ctx context.Context, key string, limit int, window time.Duration) (time.Duration, error) {
+	return ratelimit.Hit(ctx, key, limit, window)
+}
+
+// abortRateLimited answers 429 with a Retry-After header
+func abortRateLimited(ctx *gin.Context, wait time.Duration) {
+	retryAfter := max(int(math.Ceil(wait.Seconds())), 1)
+	ctx.Header("Retry-After", strconv.Itoa(retryAfter))
+	ctx.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests", "retry_after": retryAfter})
+}
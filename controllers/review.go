@@ -0,0 +1,287 @@
+package controllers
+
+import (
+	"database/sql"
+	"net/http"
+	"reviser/internal/inits"
+	"reviser/internal/models"
+	"reviser/internal/srs"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// seedReviewSchedule schedules a first revision of the question one day
+// after an accepted submission, unless the user already has an entry
+func seedReviewSchedule(ctx *gin.Context, tx *sql.Tx, username, slug string, submittedAt time.Time) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO review_schedule (username, question_slug, ease_factor, due_at)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (username, question_slug) DO NOTHING`,
+		username, slug, srs.DefaultEaseFactor, submittedAt.AddDate(0, 0, 1))
+	return err
+}
+
+// dueReviewOrder lists the most overdue reviews first and reviewLogOrder
+// the latest reviews first
+var (
+	dueReviewOrder = []keysetColumn{
+		{expr: "r.due_at", cast: "timestamptz"},
+		{expr: "r.question_slug", cast: "text"},
+	}
+	reviewLogOrder = []keysetColumn{
+		{expr: "reviewed_at", cast: "timestamptz", desc: true},
+		{expr: "review_id", cast: "bigint", desc: true},
+	}
+)
+
+// FetchDueReviews retrieves the questions due for revision by the end of today
+func FetchDueReviews(ctx *gin.Context) {
+	user, ok := currentUser(ctx)
+	if !ok {
+		ctx.JSON(500, gin.H{"error": "User not found in context"})
+		return
+	}
+
+	page, ok := parsePageRequest(ctx, dueReviewOrder, defaultPageSize, maxPageSize)
+	if !ok {
+		return
+	}
+	after, afterArgs := page.where(3)
+	order, orderArgs := page.orderBy(3 + len(afterArgs))
+
+	now := time.Now().UTC()
+	endOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1)
+	query := `
+		SELECT
+			r.username, r.question_slug, r.ease_factor, r.interval_days,
+			r.repetitions, r.due_at, r.last_reviewed_at,
+			q.title, q.description
+		FROM review_schedule r
+		JOIN leetcode_questions q ON r.question_slug = q.slug
+		WHERE r.username = $1 AND r.due_at < $2
+			AND ` + after + `
+		` + order
+	rows, err := inits.DB.QueryContext(ctx, query,
+		append(append([]interface{}{user.Username, endOfDay}, afterArgs...), orderArgs...)...)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	type dueReview struct {
+		Schedule models.Review_Schedule
+		Question models.Leetcode_Questions
+	}
+	var results []dueReview
+	for rows.Next() {
+		var r dueReview
+		if err := rows.Scan(
+			&r.Schedule.Username,
+			&r.Schedule.Question_Slug,
+			&r.Schedule.Ease_Factor,
+			&r.Schedule.Interval_Days,
+			&r.Schedule.Repetitions,
+			&r.Schedule.Due_At,
+			&r.Schedule.Last_Reviewed_At,
+			&r.Question.Title,
+			&r.Question.Description,
+		); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan row"})
+			return
+		}
+		r.Question.Slug = r.Schedule.Question_Slug
+		results = append(results, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Row iteration error"})
+		return
+	}
+
+	results, response := finishPage(page, results, func(r dueReview) []string {
+		return []string{timeKey(r.Schedule.Due_At), r.Schedule.Question_Slug}
+	})
+	response["reviews"] = results
+	ctx.JSON(200, response)
+}
+
+// GradeReview records a review in the review log and
+// schedules the next revision using SM-2
+func GradeReview(ctx *gin.Context) {
+	user, ok := currentUser(ctx)
+	if !ok {
+		ctx.JSON(500, gin.H{"error": "User not found in context"})
+		return
+	}
+
+	slug := ctx.Param("slug")
+	if slug == "" {
+		ctx.JSON(400, gin.H{"error": "Slug is required"})
+		return
+	}
+
+	var body struct {
+		Grade              *int
+		Time_Spent_Seconds int
+		Notes              string
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+		return
+	}
+	if body.Grade == nil || !srs.ValidGrade(*body.Grade) {
+		ctx.JSON(400, gin.H{"error": "Grade must be between 0 and 5"})
+		return
+	}
+	if body.Time_Spent_Seconds < 0 {
+		ctx.JSON(400, gin.H{"error": "Time spent cannot be negative"})
+		return
+	}
+
+	tx, err := inits.DB.BeginTx(ctx, nil)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM leetcode_questions WHERE slug = $1)", slug).Scan(&exists)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if !exists {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Question not found", "slug": slug})
+		return
+	}
+
+	card := srs.NewCard()
+	err = tx.QueryRowContext(ctx,
+		`SELECT ease_factor, interval_days, repetitions
+			FROM review_schedule
+			WHERE username = $1 AND question_slug = $2
+			FOR UPDATE`,
+		user.Username, slug).Scan(&card.EaseFactor, &card.IntervalDays, &card.Repetitions)
+	if err != nil && err != sql.ErrNoRows {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	reviewedAt := time.Now().UTC()
+	card, dueAt := srs.Review(card, *body.Grade, reviewedAt)
+
+	review := models.Review_Log{
+		Username:           user.Username,
+		Question_Slug:      slug,
+		Grade:              *body.Grade,
+		Time_Spent_Seconds: body.Time_Spent_Seconds,
+		Notes:              body.Notes,
+		Reviewed_At:        reviewedAt,
+	}
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO review_log (username, question_slug, grade, time_spent_seconds, notes, reviewed_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING review_id`,
+		review.Username, review.Question_Slug, review.Grade, review.Time_Spent_Seconds,
+		review.Notes, review.Reviewed_At).Scan(&review.Review_ID)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to record review", "details": err.Error()})
+		return
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO review_schedule
+			(username, question_slug, ease_factor, interval_days, repetitions, due_at, last_reviewed_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (username, question_slug)
+			DO UPDATE SET ease_factor = $3, interval_days = $4, repetitions = $5,
+				due_at = $6, last_reviewed_at = $7`,
+		user.Username, slug, card.EaseFactor, card.IntervalDays, card.Repetitions, dueAt, reviewedAt)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to update review schedule", "details": err.Error()})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to update review schedule", "details": err.Error()})
+		return
+	}
+
+	ctx.JSON(200, gin.H{"review": review, "schedule": models.Review_Schedule{
+		Username:         user.Username,
+		Question_Slug:    slug,
+		Ease_Factor:      card.EaseFactor,
+		Interval_Days:    card.IntervalDays,
+		Repetitions:      card.Repetitions,
+		Due_At:           dueAt,
+		Last_Reviewed_At: &reviewedAt,
+	}})
+}
+
+// FetchReviewHistory retrieves the review log of the current user for a slug
+func FetchReviewHistory(ctx *gin.Context) {
+	user, ok := currentUser(ctx)
+	if !ok {
+		ctx.JSON(500, gin.H{"error": "User not found in context"})
+		return
+	}
+
+	slug := ctx.Param("slug")
+	if slug == "" {
+		ctx.JSON(400, gin.H{"error": "Slug is required"})
+		return
+	}
+
+	page, ok := parsePageRequest(ctx, reviewLogOrder, defaultPageSize, maxPageSize)
+	if !ok {
+		return
+	}
+	after, afterArgs := page.where(3)
+	order, orderArgs := page.orderBy(3 + len(afterArgs))
+
+	query := `
+		SELECT review_id, username, question_slug, grade, time_spent_seconds, notes, reviewed_at
+		FROM review_log
+		WHERE username = $1 AND question_slug = $2
+			AND ` + after + `
+		` + order
+	rows, err := inits.DB.QueryContext(ctx, query,
+		append(append([]interface{}{user.Username, slug}, afterArgs...), orderArgs...)...)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	var reviews []models.Review_Log
+	for rows.Next() {
+		var r models.Review_Log
+		if err := rows.Scan(
+			&r.Review_ID,
+			&r.Username,
+			&r.Question_Slug,
+			&r.Grade,
+			&r.Time_Spent_Seconds,
+			&r.Notes,
+			&r.Reviewed_At,
+		); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan row"})
+			return
+		}
+		reviews = append(reviews, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Row iteration error"})
+		return
+	}
+
+	reviews, response := finishPage(page, reviews, func(r models.Review_Log) []string {
+		return []string{timeKey(r.Reviewed_At), strconv.FormatUint(uint64(r.Review_ID), 10)}
+	})
+	response["reviews"] = reviews
+	ctx.JSON(200, response)
+}
@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"net/http"
+	"reviser/internal/inits"
+	"reviser/internal/models"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+const (
+	defaultSearchPageSize = 20
+	maxSearchPageSize     = 100
+)
+
+// searchOrder lists the best matches first. Questions have no
+// submission id and sort before the submissions of the same slug
+var searchOrder = []keysetColumn{
+	{expr: "m.rank", cast: "real", desc: true},
+	{expr: "m.slug", cast: "text"},
+	{expr: "COALESCE(m.submission_id, 0)", cast: "bigint"},
+}
+
+// splitList parses a comma separated query parameter, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// htmlEscapeSQL wraps a text expression so Postgres HTML escapes it.
+// Descriptions and code are stored as submitted, so they are escaped before
+// ts_headline adds its <mark> tags and the snippet is safe to render as HTML
+func htmlEscapeSQL(expr string) string {
+	return `replace(replace(replace(replace(replace(` + expr + `,
+		'&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), '''', '&#39;')`
+}
+
+// SearchContent runs a ranked full-text search over the titles and
+// descriptions of the user's questions and the code of their submissions.
+// Results can be filtered by tags (all must match) and a submission date
+// range, and are paginated with limit and cursor
+func SearchContent(ctx *gin.Context) {
+	user, ok := currentUser(ctx)
+	if !ok {
+		ctx.JSON(500, gin.H{"error": "User not found in context"})
+		return
+	}
+
+	q := strings.TrimSpace(ctx.Query("q"))
+	if q == "" {
+		ctx.JSON(400, gin.H{"error": "Query is required"})
+		return
+	}
+	from, ok := parseOptionalTime(ctx, "from")
+	if !ok {
+		return
+	}
+	to, ok := parseOptionalTime(ctx, "to")
+	if !ok {
+		return
+	}
+
+	page, ok := parsePageRequest(ctx, searchOrder, defaultSearchPageSize, maxSearchPageSize)
+	if !ok {
+		return
+	}
+	tags := tagFilter{Tags: normalizeTagNames(splitList(ctx.Query("tags"))), MatchAll: true}
+	after, afterArgs := page.where(6)
+	order, orderArgs := page.orderBy(6 + len(afterArgs))
+
+	matches := `
+		WITH matches AS (
+			SELECT 'question' AS kind, q.slug, q.title, NULL::bigint AS submission_id,
+				NULL::timestamptz AS submitted_at,
+				ts_rank(q.search_vector, websearch_to_tsquery('english', $2)) AS rank,
+				q.description AS document, 'english'::regconfig AS config
+			FROM leetcode_questions q
+			WHERE q.search_vector @@ websearch_to_tsquery('english', $2)
+				AND EXISTS (
+					SELECT 1 FROM leetcode_submissions s
+					WHERE s.question_slug = q.slug AND s.username = $1
+						AND ($4::timestamptz IS NULL OR s.submitted_at >= $4)
+						AND ($5::timestamptz IS NULL OR s.submitted_at < $5)
+				)
+			UNION ALL
+			SELECT 'submission', s.question_slug, q.title, s.submission_id, s.submitted_at,
+				ts_rank(s.code_vector, websearch_to_tsquery('simple', $2)),
+				s.code, 'simple'::regconfig
+			FROM leetcode_submissions s
+			JOIN leetcode_questions q ON s.question_slug = q.slug
+			WHERE s.username = $1
+				AND s.code_vector @@ websearch_to_tsquery('simple', $2)
+				AND ($4::timestamptz IS NULL OR s.submitted_at >= $4)
+				AND ($5::timestamptz IS NULL OR s.submitted_at < $5)
+		),
+		filtered AS (
+			SELECT * FROM matches m
+			WHERE ` + tags.clause("m.slug", 1, 3) + `
+		)`
+	args := []interface{}{user.Username, q, pq.Array(tags.Tags), from, to}
+
+	// counted apart from the page, which is empty past the last result
+	var total int64
+	if err := inits.DB.QueryRowContext(ctx, matches+" SELECT COUNT(*) FROM filtered", args...).Scan(&total); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	// snippets are only highlighted for the requested page
+	query := matches + `,
+		page AS (
+			SELECT * FROM filtered m
+			WHERE ` + after + `
+			` + order + `
+		)
+		SELECT kind, slug, title, submission_id, submitted_at, rank,
+			ts_headline(config, ` + htmlEscapeSQL("document") + `,
+				CASE WHEN kind = 'question' THEN websearch_to_tsquery('english', $2)
+					ELSE websearch_to_tsquery('simple', $2) END,
+				'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5')
+		FROM page m
+		` + page.order()
+	rows, err := inits.DB.QueryContext(ctx, query, append(append(args, afterArgs...), orderArgs...)...)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	var results []models.Search_Result
+	for rows.Next() {
+		var r models.Search_Result
+		if err := rows.Scan(
+			&r.Kind,
+			&r.Question_Slug,
+			&r.Title,
+			&r.Submission_ID,
+			&r.Submitted_At,
+			&r.Rank,
+			&r.Snippet,
+		); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan row"})
+			return
+		}
+		results = append(results, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Row iteration error"})
+		return
+	}
+
+	results, response := finishPage(page, results, func(r models.Search_Result) []string {
+		var submissionID uint
+		if r.Submission_ID != nil {
+			submissionID = *r.Submission_ID
+		}
+		return []string{
+			strconv.FormatFloat(r.Rank, 'g', -1, 64), r.Question_Slug, strconv.FormatUint(uint64(submissionID), 10),
+		}
+	})
+	response["results"] = results
+	response["total"] = total
+	ctx.JSON(200, response)
+}
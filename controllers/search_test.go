@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"database/sql/driver"
+	"net/url"
+	"reviser/internal/models"
+	"testing"
+)
+
+func TestSearchTotalPastLastResult(t *testing.T) {
+	db := useFakeDB(t, fakeResult{
+		match:   "SELECT COUNT(*) FROM filtered",
+		columns: []string{"count"},
+		rows:    [][]driver.Value{{int64(7)}},
+	})
+	cursor := encodeCursor(pageCursor{Keys: []string{"0.01", "two-sum", "3"}})
+	target := "/api/content/search?q=hash&cursor=" + url.QueryEscape(cursor)
+	status, response := serve(t, SearchContent, models.User{Username: "alice"}, "", "GET", target, nil)
+	if status != 200 {
+		t.Fatalf("status = %d: %v", status, response)
+	}
+	if !db.ran("page AS (") {
+		t.Fatal("the page was not queried")
+	}
+	if response["total"] != float64(7) {
+		t.Errorf("total = %v, want 7", response["total"])
+	}
+	if response["results"] != nil || response["next_cursor"] != nil {
+		t.Errorf("results past the last one: %v", response)
+	}
+}
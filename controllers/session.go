@@ -0,0 +1,270 @@
+package controllers
+
+import (
+	"database/sql"
+	"net/http"
+	"reviser/internal/audit"
+	"reviser/internal/inits"
+	"reviser/internal/models"
+	"reviser/internal/tokens"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	refreshCookie = "Refresh"
+	// csrfCookie is readable by scripts so same-site frontends can echo it
+	// back in the X-CSRF-Token header
+	csrfCookie = "csrf_token"
+)
+
+// Login and Refresh deliver tokens as cookies by default. Clients that send
+// Auth_Mode "header" get them in the response body instead and present the
+// access token as "Authorization: Bearer <jwt>"
+const (
+	authModeCookie = "cookie"
+	authModeHeader = "header"
+)
+
+func setSameSite(ctx *gin.Context) {
+	if secure {
+		ctx.SetSameSite(http.SameSiteNoneMode)
+	} else {
+		ctx.SetSameSite(http.SameSiteLaxMode)
+	}
+}
+
+// sessionTokens are the credentials handed to a client for one session
+type sessionTokens struct {
+	access  string
+	refresh string
+	csrf    string
+}
+
+// setAuthCookies stores the access and CSRF tokens for every route and
+// the refresh token only for the /auth routes
+func setAuthCookies(ctx *gin.Context, t sessionTokens) {
+	setSameSite(ctx)
+	ctx.SetCookie("Authorization", t.access, int(tokens.AccessTokenTTL.Seconds()), "/", domain, secure, true)
+	ctx.SetCookie(refreshCookie, t.refresh, int(tokens.RefreshTokenTTL.Seconds()), "/auth", domain, secure, true)
+	ctx.SetCookie(csrfCookie, t.csrf, int(tokens.RefreshTokenTTL.Seconds()), "/", domain, secure, false)
+}
+
+func clearAuthCookies(ctx *gin.Context) {
+	setSameSite(ctx)
+	ctx.SetCookie("Authorization", "", -1, "/", domain, secure, true)
+	ctx.SetCookie(refreshCookie, "", -1, "/auth", domain, secure, true)
+	ctx.SetCookie(csrfCookie, "", -1, "/", domain, secure, false)
+}
+
+func validAuthMode(mode string) bool {
+	return mode == "" || mode == authModeCookie || mode == authModeHeader
+}
+
+// deliverTokens sets the auth cookies, or for header mode adds the tokens
+// to the response body, and returns the body. Cookie clients also get the
+// CSRF token in the body since cross-site frontends cannot read the cookie
+func deliverTokens(ctx *gin.Context, mode string, t sessionTokens, body gin.H) gin.H {
+	if mode != authModeHeader {
+		setAuthCookies(ctx, t)
+		body["csrf_token"] = t.csrf
+		return body
+	}
+	body["access_token"] = t.access
+	body["refresh_token"] = t.refresh
+	body["token_type"] = "Bearer"
+	body["expires_in"] = int(tokens.AccessTokenTTL.Seconds())
+	return body
+}
+
+// startSession creates a new refresh token family for the user and
+// returns its first tokens
+func startSession(ctx *gin.Context, user models.User) (sessionTokens, error) {
+	var t sessionTokens
+	sessionID, err := tokens.NewSessionID()
+	if err != nil {
+		return t, err
+	}
+	refreshToken, refreshHash, err := tokens.NewOpaqueToken()
+	if err != nil {
+		return t, err
+	}
+	csrfToken, _, err := tokens.NewOpaqueToken()
+	if err != nil {
+		return t, err
+	}
+
+	tx, err := inits.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return t, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO sessions (session_id, username, user_agent, ip, csrf_token) VALUES ($1, $2, $3, $4, $5)",
+		sessionID, user.Username, ctx.Request.UserAgent(), ctx.ClientIP(), csrfToken)
+	if err != nil {
+		return t, err
+	}
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO refresh_tokens (token_hash, session_id, expires_at) VALUES ($1, $2, $3)",
+		refreshHash, sessionID, time.Now().Add(tokens.RefreshTokenTTL))
+	if err != nil {
+		return t, err
+	}
+
+	accessToken, err := tokens.SignAccessToken(user.Username, user.Role, sessionID)
+	if err != nil {
+		return t, err
+	}
+	if err := tx.Commit(); err != nil {
+		return t, err
+	}
+	return sessionTokens{access: accessToken, refresh: refreshToken, csrf: csrfToken}, nil
+}
+
+// Refresh rotates the refresh token and issues a new access token.
+// Presenting an already used refresh token revokes the whole session.
+// Header mode clients send the refresh token as Refresh_Token in the body
+func Refresh(ctx *gin.Context) {
+	mode := authModeCookie
+	refreshToken, err := ctx.Cookie(refreshCookie)
+	if err != nil || refreshToken == "" {
+		var body struct {
+			Refresh_Token string
+		}
+		if ctx.ShouldBindJSON(&body) != nil || body.Refresh_Token == "" {
+			ctx.JSON(401, gin.H{"error": "unauthorized", "message": "No refresh token provided"})
+			return
+		}
+		mode = authModeHeader
+		refreshToken = body.Refresh_Token
+	}
+
+	tx, err := inits.DB.BeginTx(ctx, nil)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	var user models.User
+	var sessionID, csrfToken string
+	var expiresAt time.Time
+	var usedAt, revokedAt *time.Time
+	err = tx.QueryRowContext(ctx,
+		`SELECT rt.session_id, rt.expires_at, rt.used_at, s.revoked_at, s.csrf_token,
+				u.name, u.username, u.role
+			FROM refresh_tokens rt
+			JOIN sessions s ON rt.session_id = s.session_id
+			JOIN users u ON s.username = u.username
+			WHERE rt.token_hash = $1
+			FOR UPDATE OF rt, s`,
+		tokens.HashOpaqueToken(refreshToken)).Scan(
+		&sessionID, &expiresAt, &usedAt, &revokedAt, &csrfToken, &user.Name, &user.Username, &user.Role)
+	if err == sql.ErrNoRows {
+		clearAuthCookies(ctx)
+		ctx.JSON(401, gin.H{"error": "unauthorized", "message": "Invalid refresh token"})
+		return
+	} else if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if revokedAt != nil {
+		clearAuthCookies(ctx)
+		ctx.JSON(401, gin.H{"error": "unauthorized", "message": "Session revoked"})
+		return
+	}
+	if usedAt != nil {
+		// a rotated token was replayed, so the family is compromised
+		if _, err := tx.ExecContext(ctx, "UPDATE sessions SET revoked_at = now() WHERE session_id = $1", sessionID); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		clearAuthCookies(ctx)
+		audit.Record(ctx, audit.EventRefreshReuse, user.Username, "session="+sessionID)
+		ctx.JSON(401, gin.H{"error": "unauthorized", "message": "Refresh token reuse detected"})
+		return
+	}
+	if time.Now().After(expiresAt) {
+		clearAuthCookies(ctx)
+		ctx.JSON(401, gin.H{"error": "unauthorized", "message": "Refresh token expired"})
+		return
+	}
+	if csrfToken == "" {
+		// sessions created before CSRF protection get their token on first refresh
+		if csrfToken, _, err = tokens.NewOpaqueToken(); err != nil {
+			ctx.JSON(500, gin.H{"error": "Failed to generate CSRF token"})
+			return
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE sessions SET csrf_token = $1 WHERE session_id = $2", csrfToken, sessionID); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+	}
+
+	newRefreshToken, newRefreshHash, err := tokens.NewOpaqueToken()
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to generate refresh token"})
+		return
+	}
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE refresh_tokens SET used_at = now() WHERE token_hash = $1",
+		tokens.HashOpaqueToken(refreshToken)); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO refresh_tokens (token_hash, session_id, expires_at) VALUES ($1, $2, $3)",
+		newRefreshHash, sessionID, time.Now().Add(tokens.RefreshTokenTTL)); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	accessToken, err := tokens.SignAccessToken(user.Username, user.Role, sessionID)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "error signing token"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	t := sessionTokens{access: accessToken, refresh: newRefreshToken, csrf: csrfToken}
+	ctx.JSON(200, deliverTokens(ctx, mode, t,
+		gin.H{"data": "Token refreshed", "user": user.Username}))
+}
+
+// LogoutAll revokes every session of the current user
+func LogoutAll(ctx *gin.Context) {
+	user, ok := currentUser(ctx)
+	if !ok {
+		ctx.JSON(500, gin.H{"error": "User not found in context"})
+		return
+	}
+
+	_, err := inits.DB.ExecContext(ctx,
+		"UPDATE sessions SET revoked_at = now() WHERE username = $1 AND revoked_at IS NULL",
+		user.Username)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to revoke sessions", "details": err.Error()})
+		return
+	}
+
+	clearAuthCookies(ctx)
+	audit.Record(ctx, audit.EventLogoutAll, user.Username, "")
+	ctx.JSON(200, gin.H{"data": "All sessions logged out!"})
+}
+
+// CSRFToken returns the CSRF token of the current session, which cookie
+// authenticated clients must send as X-CSRF-Token on mutating requests
+func CSRFToken(ctx *gin.Context) {
+	ctx.JSON(200, gin.H{"csrf_token": ctx.GetString("csrf_token")})
+}
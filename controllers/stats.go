@@ -0,0 +1,277 @@
+package controllers
+
+import (
+	"net/http"
+	"reviser/internal/inits"
+	"reviser/internal/judge"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultHeatmapDays = 365
+	maxHeatmapDays     = 5 * 366
+	// streaks are counted over this many days before today at most
+	streakLookbackDays = 3 * 365
+	defaultStaleDays   = 30
+	// solved_per_tag and stale hold at most this many entries, with the
+	// full counts in solved_tags and stale_total
+	statsListLimit = 100
+)
+
+// streaks returns the run of consecutive days ending today or yesterday
+// and the longest run. days must be sorted, distinct calendar dates
+func streaks(days []time.Time, today time.Time) (current int, longest int) {
+	run := 0
+	for i, day := range days {
+		if i > 0 && days[i-1].AddDate(0, 0, 1).Equal(day) {
+			run++
+		} else {
+			run = 1
+		}
+		longest = max(longest, run)
+	}
+	if len(days) > 0 {
+		last := days[len(days)-1]
+		if last.Equal(today) || last.Equal(today.AddDate(0, 0, -1)) {
+			current = run
+		}
+	}
+	return current, longest
+}
+
+// FetchStats returns practice statistics of the current user: submissions
+// per day for a heatmap (from and to, the last year by default), current
+// and longest streak, solved questions per tag, questions not touched in
+// stale_days days, the longest untouched first, and the average time from
+// first solve to last revision. Both lists stop at statsListLimit entries.
+// Days are read in the tz parameter or the user's timezone. Streaks only
+// look at the heatmap range and the last streakLookbackDays days
+func FetchStats(ctx *gin.Context) {
+	user, ok := currentUser(ctx)
+	if !ok {
+		ctx.JSON(500, gin.H{"error": "User not found in context"})
+		return
+	}
+
+	loc, ok := requestLocation(ctx, user)
+	if !ok {
+		return
+	}
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	from, ok := parseRangeBound(ctx, "from", loc, false)
+	if !ok {
+		return
+	}
+	to, ok := parseRangeBound(ctx, "to", loc, true)
+	if !ok {
+		return
+	}
+	if to == nil {
+		end := today.AddDate(0, 0, 1)
+		to = &end
+	}
+	if from == nil {
+		start := to.AddDate(0, 0, -defaultHeatmapDays)
+		from = &start
+	}
+	if from.After(*to) {
+		ctx.JSON(400, gin.H{"error": "'from' must not be after 'to'"})
+		return
+	}
+	if to.Sub(*from) > maxHeatmapDays*24*time.Hour {
+		ctx.JSON(400, gin.H{"error": "Range is longer than " + strconv.Itoa(maxHeatmapDays) + " days"})
+		return
+	}
+	staleDays := defaultStaleDays
+	if value := ctx.Query("stale_days"); value != "" {
+		var err error
+		if staleDays, err = strconv.Atoi(value); err != nil || staleDays < 0 {
+			ctx.JSON(400, gin.H{"error": "invalid 'stale_days' query parameter"})
+			return
+		}
+	}
+
+	// only the heatmap range and the streak lookback are scanned
+	lookback := today.AddDate(0, 0, -streakLookbackDays)
+	tomorrow := today.AddDate(0, 0, 1)
+	// submissions are counted per 15 minutes in UTC and the calendar days
+	// are taken here, every zone offset in use being a multiple of 15
+	// minutes. Postgres never sees the zone, whose tzdata may differ
+	rows, err := inits.DB.QueryContext(ctx, `
+		SELECT to_timestamp(floor(extract(epoch FROM s.submitted_at) / 900) * 900) AS slot, COUNT(*),
+			bool_or(s.submitted_at >= $2 AND s.submitted_at < $3)
+		FROM leetcode_submissions s
+		WHERE s.username = $1
+			AND (s.submitted_at >= $2 AND s.submitted_at < $3
+				OR s.submitted_at >= $4 AND s.submitted_at < $5)
+		GROUP BY slot`,
+		user.Username, *from, *to, lookback, tomorrow)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	type heatmapDay struct {
+		Day   string
+		Count int64
+	}
+	type dayTotal struct {
+		count   int64
+		inRange bool
+	}
+	// a zone turning its clocks back over midnight revisits a day, so
+	// slots are summed per day before the days are sorted
+	totals := make(map[time.Time]*dayTotal)
+	var days []time.Time
+	for rows.Next() {
+		var slot time.Time
+		var count int64
+		var inRange bool
+		if err := rows.Scan(&slot, &count, &inRange); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan row"})
+			return
+		}
+		slot = slot.In(loc)
+		day := time.Date(slot.Year(), slot.Month(), slot.Day(), 0, 0, 0, 0, loc)
+		total, seen := totals[day]
+		if !seen {
+			total = &dayTotal{}
+			totals[day] = total
+			days = append(days, day)
+		}
+		total.count += count
+		total.inRange = total.inRange || inRange
+	}
+	if err := rows.Err(); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Row iteration error"})
+		return
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	heatmap := []heatmapDay{}
+	for _, day := range days {
+		if total := totals[day]; total.inRange {
+			heatmap = append(heatmap, heatmapDay{Day: day.Format(dateLayout), Count: total.count})
+		}
+	}
+	currentStreak, longestStreak := streaks(days, today)
+
+	rows, err = inits.DB.QueryContext(ctx, `
+		SELECT t.tag_id, t.name, COUNT(DISTINCT l.slug), COUNT(*) OVER ()
+		FROM question_tag_links l
+		JOIN tags t ON l.tag_id = t.tag_id
+		WHERE l.username = $1
+			AND EXISTS (
+				SELECT 1 FROM leetcode_submissions s
+				WHERE s.username = l.username AND s.question_slug = l.slug AND s.status = $2
+			)
+		GROUP BY t.tag_id, t.name
+		ORDER BY COUNT(DISTINCT l.slug) DESC, t.name
+		LIMIT $3`,
+		user.Username, judge.StatusAccepted, statsListLimit)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	var solvedPerTag []struct {
+		Tag_ID int64
+		Tag    string
+		Solved int64
+	}
+	var solvedTags int64
+	for rows.Next() {
+		var t struct {
+			Tag_ID int64
+			Tag    string
+			Solved int64
+		}
+		if err := rows.Scan(&t.Tag_ID, &t.Tag, &t.Solved, &solvedTags); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan row"})
+			return
+		}
+		solvedPerTag = append(solvedPerTag, t)
+	}
+	if err := rows.Err(); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Row iteration error"})
+		return
+	}
+
+	// a question is touched by a submission or a logged review
+	rows, err = inits.DB.QueryContext(ctx, `
+		WITH touches AS (
+			SELECT question_slug, submitted_at AS touched_at, status = $2 AS solve
+			FROM leetcode_submissions WHERE username = $1
+			UNION ALL
+			SELECT question_slug, reviewed_at, false
+			FROM review_log WHERE username = $1
+		)
+		SELECT t.question_slug, q.title, MIN(t.touched_at) FILTER (WHERE t.solve), MAX(t.touched_at)
+		FROM touches t
+		JOIN leetcode_questions q ON t.question_slug = q.slug
+		GROUP BY t.question_slug, q.title`,
+		user.Username, judge.StatusAccepted)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	type staleQuestion struct {
+		Question_Slug string
+		Title         string
+		Last_Touched  time.Time
+	}
+	staleBefore := now.AddDate(0, 0, -staleDays)
+	var stale []staleQuestion
+	var revisedDays float64
+	var revised int64
+	for rows.Next() {
+		var q staleQuestion
+		var firstSolve *time.Time
+		if err := rows.Scan(&q.Question_Slug, &q.Title, &firstSolve, &q.Last_Touched); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan row"})
+			return
+		}
+		if q.Last_Touched.Before(staleBefore) {
+			stale = append(stale, q)
+		}
+		if firstSolve != nil && q.Last_Touched.After(*firstSolve) {
+			revisedDays += q.Last_Touched.Sub(*firstSolve).Hours() / 24
+			revised++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Row iteration error"})
+		return
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i].Last_Touched.Before(stale[j].Last_Touched) })
+	staleTotal := len(stale)
+	stale = stale[:min(staleTotal, statsListLimit)]
+
+	var avgDays float64
+	if revised > 0 {
+		avgDays = revisedDays / float64(revised)
+	}
+
+	ctx.JSON(200, gin.H{
+		"timezone":                  loc.String(),
+		"heatmap":                   heatmap,
+		"current_streak":            currentStreak,
+		"longest_streak":            longestStreak,
+		"solved_per_tag":            solvedPerTag,
+		"solved_tags":               solvedTags,
+		"stale_days":                staleDays,
+		"stale":                     stale,
+		"stale_total":               staleTotal,
+		"avg_days_to_last_revision": avgDays,
+		"revised_questions":         revised,
+	})
+}
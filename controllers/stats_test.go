@@ -0,0 +1,32 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStreaks(t *testing.T) {
+	today := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	day := func(offset int) time.Time { return today.AddDate(0, 0, offset) }
+	tests := []struct {
+		name                  string
+		days                  []time.Time
+		wantCurrent, wantLong int
+	}{
+		{"no submissions", nil, 0, 0},
+		{"today only", []time.Time{day(0)}, 1, 1},
+		{"run ending yesterday", []time.Time{day(-3), day(-2), day(-1)}, 3, 3},
+		{"run broken two days ago", []time.Time{day(-5), day(-4), day(-2)}, 0, 2},
+		{"longest run in the past", []time.Time{day(-20), day(-19), day(-18), day(-1), day(0)}, 2, 3},
+		// days of disjoint scan windows never join
+		{"disjoint windows", []time.Time{day(-2000), day(-1999), day(0)}, 1, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current, longest := streaks(tt.days, today)
+			if current != tt.wantCurrent || longest != tt.wantLong {
+				t.Errorf("streaks = %d, %d, want %d, %d", current, longest, tt.wantCurrent, tt.wantLong)
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"reviser/internal/inits"
+	"reviser/internal/models"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// tagColumns selects a models.Tag from the tags table aliased as t
+const tagColumns = `t.tag_id, t.name, t.parent_id, t.color,
+	(SELECT COALESCE(jsonb_agg(a.alias ORDER BY a.alias), '[]'::jsonb)
+		FROM tag_aliases a WHERE a.tag_id = t.tag_id)`
+
+// tagCount is a tag with the number of questions carrying it
+type tagCount struct {
+	Tag   models.Tag
+	Count int64
+}
+
+// tagCountOrder lists the most used tags first
+var tagCountOrder = []keysetColumn{
+	{expr: "c.questions", cast: "bigint", desc: true},
+	{expr: "t.name", cast: "text"},
+	{expr: "t.tag_id", cast: "bigint"},
+}
+
+func scanTag(rows *sql.Rows, tag *models.Tag, dest ...interface{}) error {
+	return rows.Scan(append([]interface{}{&tag.Tag_ID, &tag.Name, &tag.Parent_ID, &tag.Color, &tag.Aliases}, dest...)...)
+}
+
+// tagNamesSQL selects the canonical tag names of the question identified
+// by the slug and username columns as a JSON array
+func tagNamesSQL(slugColumn string, usernameColumn string) string {
+	return fmt.Sprintf(`(SELECT COALESCE(jsonb_agg(tg.name ORDER BY tg.name), '[]'::jsonb)
+		FROM question_tag_links l JOIN tags tg ON l.tag_id = tg.tag_id
+		WHERE l.slug = %s AND l.username = %s)`, slugColumn, usernameColumn)
+}
+
+// resolveTags returns the ids of the tags named or aliased by names,
+// creating tags for names that are not known yet
+func resolveTags(ctx context.Context, tx *sql.Tx, names []string) ([]int64, error) {
+	ids := []int64{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		var id int64
+		err := tx.QueryRowContext(ctx, "SELECT tag_id FROM tag_names WHERE name = $1", strings.ToLower(name)).Scan(&id)
+		if err == sql.ErrNoRows {
+			err = tx.QueryRowContext(ctx,
+				`INSERT INTO tags (name) VALUES ($1)
+					ON CONFLICT (lower(name)) DO UPDATE SET name = tags.name
+					RETURNING tag_id`, name).Scan(&id)
+		}
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// tagFilter is the "tags" (comma separated) and "match" (any or all)
+// query parameters accepted by list endpoints
+type tagFilter struct {
+	Tags     []string
+	MatchAll bool
+}
+
+func parseTagFilter(ctx *gin.Context) (tagFilter, bool) {
+	filter := tagFilter{Tags: normalizeTagNames(splitList(ctx.Query("tags")))}
+	switch ctx.DefaultQuery("match", "any") {
+	case "any":
+	case "all":
+		filter.MatchAll = true
+	default:
+		ctx.JSON(400, gin.H{"error": "invalid 'match' query parameter, expected any or all"})
+		return filter, false
+	}
+	return filter, true
+}
+
+// normalizeTagNames trims and lower cases names the way tag_names stores
+// them and drops blanks and duplicates. The result is never nil so it binds
+// as an empty array rather than NULL
+func normalizeTagNames(names []string) []string {
+	seen := make(map[string]bool)
+	normalized := []string{}
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "" && !seen[name] {
+			seen[name] = true
+			normalized = append(normalized, name)
+		}
+	}
+	return normalized
+}
+
+// args returns the query argument used by clause
+func (f tagFilter) args() []interface{} {
+	return []interface{}{pq.Array(f.Tags)}
+}
+
+// clause returns a condition matching rows whose question, identified by
+// the slug column, carries the tags bound to tagsParam for the user bound
+// to usernameParam. A tag also matches questions carrying one of its
+// descendants and no tags matches every row. Any and all are separate
+// queries rather than one CASE, so the planner sees the actual condition
+// and drives both from the (tag_id, username) index of question_tag_links
+func (f tagFilter) clause(slugColumn string, usernameParam int, tagsParam int) string {
+	if len(f.Tags) == 0 {
+		// still references the parameter so Postgres can infer its type
+		return fmt.Sprintf("cardinality($%d::text[]) = 0", tagsParam)
+	}
+	links := fmt.Sprintf(`SELECT l.slug
+			FROM tag_names n
+			JOIN tag_closure c ON c.ancestor_id = n.tag_id
+			JOIN question_tag_links l ON l.tag_id = c.tag_id
+			WHERE n.name = ANY($%[2]d::text[]) AND l.username = $%[1]d`, usernameParam, tagsParam)
+	if !f.MatchAll {
+		return fmt.Sprintf("%s IN (%s)", slugColumn, links)
+	}
+	return fmt.Sprintf(`%s IN (%s
+			GROUP BY l.slug
+			HAVING COUNT(DISTINCT n.name) = cardinality($%d::text[]))`, slugColumn, links, tagsParam)
+}
+
+// FetchQuestionsByTags retrieves the user's questions carrying any or
+// all of the requested tags
+func FetchQuestionsByTags(ctx *gin.Context) {
+	user, ok := currentUser(ctx)
+	if !ok {
+		ctx.JSON(500, gin.H{"error": "User not found in context"})
+		return
+	}
+
+	filter, ok := parseTagFilter(ctx)
+	if !ok {
+		return
+	}
+	if len(filter.Tags) == 0 {
+		ctx.JSON(400, gin.H{"error": "Tags are required"})
+		return
+	}
+
+	page, ok := parsePageRequest(ctx, questionOrder, defaultPageSize, maxPageSize)
+	if !ok {
+		return
+	}
+	after, afterArgs := page.where(3)
+	order, orderArgs := page.orderBy(3 + len(afterArgs))
+
+	query := `
+		SELECT ` + questionColumns + `, ` + tagNamesSQL("qt.slug", "qt.username") + `
+		FROM question_tags qt
+		JOIN leetcode_questions q ON qt.slug = q.slug
+		WHERE qt.username = $1
+			AND ` + filter.clause("qt.slug", 1, 2) + `
+			AND ` + after + `
+		` + order
+	args := append([]interface{}{user.Username}, filter.args()...)
+	rows, err := inits.DB.QueryContext(ctx, query, append(append(args, afterArgs...), orderArgs...)...)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	type taggedQuestion struct {
+		Question models.Leetcode_Questions
+		Tags     models.StringArray
+	}
+	var results []taggedQuestion
+	for rows.Next() {
+		var r taggedQuestion
+		if err := rows.Scan(append(questionFields(&r.Question), &r.Tags)...); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan row"})
+			return
+		}
+		results = append(results, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Row iteration error"})
+		return
+	}
+
+	results, response := finishPage(page, results, func(r taggedQuestion) []string {
+		return []string{r.Question.Slug}
+	})
+	response["questions"] = results
+	ctx.JSON(200, response)
+}
+
+// FetchTagCounts lists every tag used by the user with the number of
+// questions carrying it
+func FetchTagCounts(ctx *gin.Context) {
+	user, ok := currentUser(ctx)
+	if !ok {
+		ctx.JSON(500, gin.H{"error": "User not found in context"})
+		return
+	}
+
+	page, ok := parsePageRequest(ctx, tagCountOrder, defaultPageSize, maxPageSize)
+	if !ok {
+		return
+	}
+	after, afterArgs := page.where(2)
+	order, orderArgs := page.orderBy(2 + len(afterArgs))
+
+	query := `
+		WITH counts AS (
+			SELECT tag_id, COUNT(*) AS questions
+			FROM question_tag_links
+			WHERE username = $1
+			GROUP BY tag_id
+		)
+		SELECT ` + tagColumns + `, c.questions
+		FROM counts c
+		JOIN tags t ON c.tag_id = t.tag_id
+		WHERE ` + after + `
+		` + order
+	rows, err := inits.DB.QueryContext(ctx, query, append(append([]interface{}{user.Username}, afterArgs...), orderArgs...)...)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	var tags []tagCount
+	for rows.Next() {
+		var t tagCount
+		if err := scanTag(rows, &t.Tag, &t.Count); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan row"})
+			return
+		}
+		tags = append(tags, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Row iteration error"})
+		return
+	}
+
+	tags, response := finishPage(page, tags, func(t tagCount) []string {
+		return []string{strconv.FormatInt(t.Count, 10), t.Tag.Name, strconv.FormatInt(t.Tag.Tag_ID, 10)}
+	})
+	response["tags"] = tags
+	ctx.JSON(200, response)
+}
@@ -0,0 +1,38 @@
+package controllers
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNormalizeTagNames(t *testing.T) {
+	tests := []struct {
+		names []string
+		want  []string
+	}{
+		{nil, []string{}},
+		{[]string{"Graph", "graph", " GRAPH "}, []string{"graph"}},
+		{[]string{"  Dynamic Programming", "dp", "", "   "}, []string{"dynamic programming", "dp"}},
+	}
+	for _, tt := range tests {
+		if got := normalizeTagNames(tt.names); got == nil || !slices.Equal(got, tt.want) {
+			t.Errorf("normalizeTagNames(%q) = %q, want %q", tt.names, got, tt.want)
+		}
+	}
+}
+
+func TestTagColorPattern(t *testing.T) {
+	for color, want := range map[string]bool{
+		"#1a2B3c":  true,
+		"#000000":  true,
+		"1a2b3c":   false,
+		"#1a2b3":   false,
+		"#1a2b3c4": false,
+		"#gggggg":  false,
+		"red":      false,
+	} {
+		if got := tagColorPattern.MatchString(color); got != want {
+			t.Errorf("tagColorPattern.MatchString(%q) = %v, want %v", color, got, want)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package controllers
+
+import (
+	"database/sql"
+	"net/http"
+	"regexp"
+	"reviser/internal/inits"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tagColorPattern matches a #rrggbb color, an empty color clears it
+var tagColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// tagOrder lists tags by name
+var tagOrder = []keysetColumn{{expr: "t.name", cast: "text"}, {expr: "t.tag_id", cast: "bigint"}}
+
+// FetchTags lists the whole tag taxonomy with the number of questions
+// carrying each tag across every user
+func FetchTags(ctx *gin.Context) {
+	page, ok := parsePageRequest(ctx, tagOrder, defaultPageSize, maxPageSize)
+	if !ok {
+		return
+	}
+	after, afterArgs := page.where(1)
+	order, orderArgs := page.orderBy(1 + len(afterArgs))
+
+	query := `
+		SELECT ` + tagColumns + `,
+			(SELECT COUNT(*) FROM question_tag_links l WHERE l.tag_id = t.tag_id)
+		FROM tags t
+		WHERE ` + after + `
+		` + order
+	rows, err := inits.DB.QueryContext(ctx, query, append(afterArgs, orderArgs...)...)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	var tags []tagCount
+	for rows.Next() {
+		var t tagCount
+		if err := scanTag(rows, &t.Tag, &t.Count); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan row"})
+			return
+		}
+		tags = append(tags, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Row iteration error"})
+		return
+	}
+
+	tags, response := finishPage(page, tags, func(t tagCount) []string {
+		return []string{t.Tag.Name, strconv.FormatInt(t.Tag.Tag_ID, 10)}
+	})
+	response["tags"] = tags
+	ctx.JSON(200, response)
+}
+
+// UpdateTag renames a tag and changes its color, parent or aliases. The
+// previous name stays resolvable as an alias. A Parent_ID of 0 detaches
+// the tag and Aliases replaces the whole alias list
+func UpdateTag(ctx *gin.Context) {
+	tagID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid tag id"})
+		return
+	}
+
+	var body struct {
+		Name      *string
+		Color     *string
+		Parent_ID *int64
+		Aliases   *[]string
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+		return
+	}
+	if body.Color != nil && *body.Color != "" && !tagColorPattern.MatchString(*body.Color) {
+		ctx.JSON(400, gin.H{"error": "Color must be #rrggbb"})
+		return
+	}
+
+	tx, err := inits.DB.BeginTx(ctx, nil)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	var name string
+	err = tx.QueryRowContext(ctx, "SELECT name FROM tags WHERE tag_id = $1 FOR UPDATE", tagID).Scan(&name)
+	if err == sql.ErrNoRows {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	} else if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if body.Aliases != nil {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM tag_aliases WHERE tag_id = $1", tagID); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		for _, alias := range normalizeTagNames(*body.Aliases) {
+			added, err := addTagAlias(ctx, tx, tagID, alias)
+			if err != nil {
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+				return
+			}
+			if !added {
+				ctx.JSON(http.StatusConflict, gin.H{"error": "Alias already names another tag", "alias": alias})
+				return
+			}
+		}
+	}
+
+	if body.Name != nil {
+		newName := strings.TrimSpace(*body.Name)
+		if newName == "" {
+			ctx.JSON(400, gin.H{"error": "Name is required"})
+			return
+		}
+		var taken bool
+		err = tx.QueryRowContext(ctx,
+			"SELECT EXISTS (SELECT 1 FROM tag_names WHERE name = $1 AND tag_id <> $2)",
+			strings.ToLower(newName), tagID).Scan(&taken)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if taken {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Tag name already taken", "name": newName})
+			return
+		}
+		if _, err := tx.ExecContext(ctx,
+			"DELETE FROM tag_aliases WHERE tag_id = $1 AND alias = $2", tagID, strings.ToLower(newName)); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE tags SET name = $1 WHERE tag_id = $2", newName, tagID); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if !strings.EqualFold(name, newName) {
+			// the old name cannot name another tag, it was unique until now
+			if _, err := addTagAlias(ctx, tx, tagID, strings.ToLower(name)); err != nil {
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+				return
+			}
+		}
+	}
+
+	if body.Color != nil {
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE tags SET color = $1 WHERE tag_id = $2", strings.ToLower(*body.Color), tagID); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+	}
+
+	if body.Parent_ID != nil {
+		var parentID *int64
+		if *body.Parent_ID != 0 {
+			parentID = body.Parent_ID
+			var exists, cycle bool
+			err = tx.QueryRowContext(ctx,
+				`SELECT EXISTS (SELECT 1 FROM tags WHERE tag_id = $2),
+					EXISTS (SELECT 1 FROM tag_closure WHERE ancestor_id = $1 AND tag_id = $2)`,
+				tagID, *parentID).Scan(&exists, &cycle)
+			if err != nil {
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+				return
+			}
+			if !exists {
+				ctx.JSON(http.StatusNotFound, gin.H{"error": "Parent tag not found"})
+				return
+			}
+			if cycle {
+				ctx.JSON(400, gin.H{"error": "A tag cannot be nested under itself or its descendants"})
+				return
+			}
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE tags SET parent_id = $1 WHERE tag_id = $2", parentID, tagID); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	ctx.JSON(200, gin.H{"status": "Tag updated successfully", "tag_id": tagID})
+}
+
+// addTagAlias points alias at the tag and reports false when the alias
+// already names another tag
+func addTagAlias(ctx *gin.Context, tx *sql.Tx, tagID int64, alias string) (bool, error) {
+	var taken bool
+	err := tx.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM tag_names WHERE name = $1 AND tag_id <> $2)", alias, tagID).Scan(&taken)
+	if err != nil || taken {
+		return false, err
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO tag_aliases (alias, tag_id)
+			SELECT $1, $2
+			WHERE NOT EXISTS (SELECT 1 FROM tags WHERE tag_id = $2 AND lower(name) = $1)
+			ON CONFLICT (alias) DO NOTHING`,
+		alias, tagID)
+	return err == nil, err
+}
+
+// MergeTag folds the tag into Into_ID in one transaction: questions, aliases
+// and child tags move over, the merged name becomes an alias and the tag
+// is deleted
+func MergeTag(ctx *gin.Context) {
+	tagID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid tag id"})
+		return
+	}
+
+	var body struct {
+		Into_ID int64
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+		return
+	}
+	if body.Into_ID == tagID {
+		ctx.JSON(400, gin.H{"error": "A tag cannot be merged into itself"})
+		return
+	}
+
+	tx, err := inits.DB.BeginTx(ctx, nil)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	var locked int
+	err = tx.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM (SELECT 1 FROM tags WHERE tag_id IN ($1, $2) FOR UPDATE) AS t",
+		tagID, body.Into_ID).Scan(&locked)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if locked != 2 {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	statements := []string{
+		// questions carrying both tags keep a single link
+		`INSERT INTO question_tag_links (username, slug, tag_id)
+			SELECT username, slug, $2 FROM question_tag_links WHERE tag_id = $1
+			ON CONFLICT DO NOTHING`,
+		`UPDATE tag_aliases SET tag_id = $2 WHERE tag_id = $1`,
+		`INSERT INTO tag_aliases (alias, tag_id)
+			SELECT lower(name), $2 FROM tags WHERE tag_id = $1
+			ON CONFLICT (alias) DO UPDATE SET tag_id = EXCLUDED.tag_id`,
+		// a target nested under the merged tag takes its place in the tree
+		`UPDATE tags SET parent_id = (SELECT parent_id FROM tags WHERE tag_id = $1)
+			WHERE tag_id = $2
+				AND EXISTS (SELECT 1 FROM tag_closure WHERE ancestor_id = $1 AND tag_id = $2)`,
+		`UPDATE tags SET parent_id = $2 WHERE parent_id = $1 AND tag_id <> $2`,
+		`DELETE FROM tags WHERE tag_id = $1`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement, tagID, body.Into_ID); err != nil {
+			ctx.JSON(500, gin.H{"error": "Failed to merge tags", "details": err.Error()})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to merge tags", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"status": "Tags merged successfully", "tag_id": body.Into_ID})
+}
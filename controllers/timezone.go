@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"errors"
+	"reviser/internal/models"
+	"time"
+	// zone data is embedded so timezones resolve on hosts without tzdata
+	_ "time/tzdata"
+
+	"github.com/gin-gonic/gin"
+)
+
+const dateLayout = "2006-01-02"
+
+// loadTimezone resolves an IANA zone name. The empty name and "Local"
+// are rejected since they depend on the server rather than the user
+func loadTimezone(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, errors.New("unknown time zone " + name)
+	}
+	return time.LoadLocation(name)
+}
+
+// requestLocation returns the zone named by the tz query parameter,
+// falling back to the user's stored timezone
+func requestLocation(ctx *gin.Context, user models.User) (*time.Location, bool) {
+	if name := ctx.Query("tz"); name != "" {
+		loc, err := loadTimezone(name)
+		if err != nil {
+			ctx.JSON(400, gin.H{"error": "invalid 'tz' query parameter, expected an IANA time zone"})
+			return nil, false
+		}
+		return loc, true
+	}
+	loc, err := loadTimezone(user.Timezone)
+	if err != nil {
+		return time.UTC, true
+	}
+	return loc, true
+}
+
+// parseRangeBound parses a date or RFC 3339 query parameter in loc. A date
+// stands for the start of that day, or for the start of the next day when
+// it ends a range, so ranges stay half-open
+func parseRangeBound(ctx *gin.Context, name string, loc *time.Location, end bool) (*time.Time, bool) {
+	value := ctx.Query(name)
+	if value == "" {
+		return nil, true
+	}
+	if day, err := time.ParseInLocation(dateLayout, value, loc); err == nil {
+		if end {
+			day = day.AddDate(0, 0, 1)
+		}
+		return &day, true
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid '" + name + "' query parameter, expected a date or RFC 3339"})
+		return nil, false
+	}
+	return &t, true
+}
@@ -2,31 +2,52 @@ package controllers
 
 import (
 	"database/sql"
+	"math"
 	"net/http"
 	"os"
+	"reviser/internal/audit"
 	"reviser/internal/inits"
 	"reviser/internal/models"
+	"reviser/internal/password"
+	"reviser/internal/rbac"
+	"reviser/internal/tokens"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v4"
+	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
 
 var domain = os.Getenv("DOMAIN")
 var secure = domain != "localhost"
 
+// dummyHash is compared against when a login names an unknown user
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("reviser-dummy-password"), 10)
+
 func Signup(ctx *gin.Context) {
 	var body struct {
-		Name     string
-		Username string
-		Password string
+		Name        string
+		Username    string
+		Password    string
+		Email       string
+		Invite_Code string
 	}
 
 	if ctx.BindJSON(&body) != nil {
 		ctx.JSON(400, gin.H{"error": "Invalid request", "message": "Error trying to parse body!"})
 		return
 	}
+	body.Username = strings.TrimSpace(body.Username)
+	if body.Username == "" {
+		ctx.JSON(400, gin.H{"error": "Invalid request", "message": "Username is required"})
+		return
+	}
+	if err := password.Validate(body.Password, body.Username); err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid password", "message": err.Error()})
+		return
+	}
 
 	hash, err := bcrypt.GenerateFromPassword([]byte(body.Password), 10)
 
@@ -35,67 +56,168 @@ func Signup(ctx *gin.Context) {
 		return
 	}
 
-	user := models.User{Name: body.Name, Username: body.Username, Password: string(hash)}
-	_, err = inits.DB.Exec("INSERT INTO users (Name, Username, Password) VALUES ($1, $2, $3)",
-		user.Name, user.Username, user.Password)
+	user := models.User{Name: body.Name, Username: body.Username, Password: string(hash), Email: strings.TrimSpace(body.Email)}
 
+	tx, err := inits.DB.BeginTx(ctx, nil)
 	if err != nil {
-		ctx.JSON(500, gin.H{"error": err, "message": "Error Trying to insert credentials to db!"})
+		ctx.JSON(500, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	if err := lockSignups(ctx, tx); err != nil {
+		ctx.JSON(500, gin.H{"error": "Database error"})
+		return
+	}
+	var bootstrap bool
+	err = tx.QueryRowContext(ctx, "SELECT NOT EXISTS (SELECT 1 FROM users)").Scan(&bootstrap)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "Database error"})
+		return
+	}
+
+	var inviteID *int64
+	switch mode := signupMode(); {
+	case bootstrap || mode == signupOpen:
+		// open signup, or the first account of a fresh deployment
+	case mode == signupClosed:
+		ctx.JSON(403, gin.H{"error": "forbidden", "message": "Signup is closed"})
+		return
+	case mode == signupInvite:
+		var id int64
+		err = tx.QueryRowContext(ctx,
+			`UPDATE invites SET uses = uses + 1
+				WHERE code_hash = $1 AND revoked_at IS NULL AND expires_at > now() AND uses < max_uses
+				RETURNING invite_id`,
+			tokens.HashOpaqueToken(strings.TrimSpace(body.Invite_Code))).Scan(&id)
+		if err == sql.ErrNoRows {
+			ctx.JSON(403, gin.H{"error": "forbidden", "message": "A valid invite code is required"})
+			return
+		} else if err != nil {
+			ctx.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+		inviteID = &id
+	}
+
+	// the first account of a fresh deployment becomes its admin
+	role := rbac.RoleViewer
+	if bootstrap {
+		role = rbac.RoleAdmin
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO users (Name, Username, Password, Email, Role, invite_id)
+			VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6)`,
+		user.Name, user.Username, user.Password, user.Email, role, inviteID)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == uniqueViolation {
+		ctx.JSON(409, gin.H{"error": "Username already taken"})
+		return
+	} else if err != nil {
+		ctx.JSON(500, gin.H{"error": "Database error", "message": "Error Trying to insert credentials to db!"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		ctx.JSON(500, gin.H{"error": "Database error", "message": "Error Trying to insert credentials to db!"})
 		return
 	}
 
+	audit.Record(ctx, audit.EventSignup, user.Username, "")
 	ctx.JSON(200, gin.H{"data": user.Name})
 }
 
+// signupLockID is the advisory lock taken by every transaction creating a
+// user, so only one of two concurrent first signups sees an empty table
+const signupLockID = 0x72657669
+
+func lockSignups(ctx *gin.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", signupLockID)
+	return err
+}
+
 func Login(ctx *gin.Context) {
 	var body struct {
-		Username string
-		Password string
+		Username  string
+		Password  string
+		Auth_Mode string
 	}
 
 	if ctx.BindJSON(&body) != nil {
 		ctx.JSON(400, gin.H{"error": "Invalid request", "message": "Error trying to parse body!"})
 		return
 	}
+	if !validAuthMode(body.Auth_Mode) {
+		ctx.JSON(400, gin.H{"error": "Invalid request", "message": "Auth_Mode must be cookie or header"})
+		return
+	}
+
+	userKey := usernameLockKey(body.Username)
+	ipKey := ipLockKey(ctx.ClientIP())
+	until, err := lockedUntil(ctx, userKey, ipKey)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "Database error"})
+		return
+	}
+	if !until.IsZero() {
+		retryAfter := int(math.Ceil(time.Until(until).Seconds()))
+		ctx.Header("Retry-After", strconv.Itoa(retryAfter))
+		audit.Record(ctx, audit.EventLoginLocked, body.Username, "")
+		ctx.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed attempts", "retry_after": retryAfter})
+		return
+	}
 
 	var user models.User
+	var mfaEnabled bool
 
-	row := inits.DB.QueryRow("SELECT Name,Username, Password FROM users WHERE Username = $1", body.Username)
+	row := inits.DB.QueryRow("SELECT Name,Username, Password, Role, totp_enabled FROM users WHERE Username = $1", body.Username)
 
-	if err := row.Scan(&user.Name, &user.Username, &user.Password); err != nil {
-		if err == sql.ErrNoRows {
-			ctx.JSON(500, gin.H{"error": "User not found"})
+	err = row.Scan(&user.Name, &user.Username, &user.Password, &user.Role, &mfaEnabled)
+	if err == sql.ErrNoRows {
+		// compare against a dummy hash so unknown usernames take as long as wrong passwords
+		user.Password = string(dummyHash)
+	} else if err != nil {
+		ctx.JSON(500, gin.H{"error": "Database error", "message": "Error Trying to check credentials from db!"})
+		return
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(body.Password)) != nil || user.Username == "" {
+		if err := recordLoginFailure(ctx, userKey, usernameFailureThreshold); err != nil {
+			ctx.JSON(500, gin.H{"error": "Database error"})
 			return
 		}
-		ctx.JSON(400, gin.H{"error": "Invalid request", "message": "Error Trying to check credentials from db!"})
+		if err := recordLoginFailure(ctx, ipKey, ipFailureThreshold); err != nil {
+			ctx.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+		audit.Record(ctx, audit.EventLoginFailure, body.Username, "")
+		ctx.JSON(401, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(body.Password))
-
-	if err != nil {
-		ctx.JSON(401, gin.H{"error": "Invalid credentials"})
+	// the failure counter is only cleared once the second factor is verified
+	if mfaEnabled {
+		mfaToken, err := tokens.SignMFAPendingToken(user.Username)
+		if err != nil {
+			ctx.JSON(500, gin.H{"error": "error signing token"})
+			return
+		}
+		audit.Record(ctx, audit.EventMFARequired, user.Username, "")
+		ctx.JSON(200, gin.H{"data": "Two-factor authentication required", "mfa_required": true, "mfa_token": mfaToken})
 		return
 	}
 
-	// generate JWT token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"username": user.Username,
-		"exp":      jwt.TimeFunc().Add(24 * time.Hour).Unix(),
-	})
-	tokenString, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	if err := clearLoginFailures(ctx, userKey); err != nil {
+		ctx.JSON(500, gin.H{"error": "Database error"})
+		return
+	}
 
+	session, err := startSession(ctx, user)
 	if err != nil {
 		ctx.JSON(500, gin.H{"error": "error signing token"})
 		return
 	}
-	if secure {
-		ctx.SetSameSite(http.SameSiteNoneMode)
-	} else {
-		ctx.SetSameSite(http.SameSiteLaxMode)
-	}
-	ctx.SetCookie("Authorization", tokenString, 3600*24*30, "/", domain, secure, true)
-	ctx.JSON(200, gin.H{"data": "Successfully logged in!", "user": body.Username})
+	audit.Record(ctx, audit.EventLoginSuccess, user.Username, "password")
+	ctx.JSON(200, deliverTokens(ctx, body.Auth_Mode, session,
+		gin.H{"data": "Successfully logged in!", "user": body.Username}))
 }
 
 func Validate(ctx *gin.Context) {
@@ -109,12 +231,118 @@ func Validate(ctx *gin.Context) {
 	ctx.JSON(200, gin.H{"data": "You are logged in!"})
 }
 
+// Logout revokes the current session and clears the auth cookies
 func Logout(ctx *gin.Context) {
-	if secure {
-		ctx.SetSameSite(http.SameSiteNoneMode)
-	} else {
-		ctx.SetSameSite(http.SameSiteLaxMode)
+	if sessionID := ctx.GetString("session_id"); sessionID != "" {
+		_, err := inits.DB.ExecContext(ctx, "UPDATE sessions SET revoked_at = now() WHERE session_id = $1", sessionID)
+		if err != nil {
+			ctx.JSON(500, gin.H{"error": "Failed to revoke session", "details": err.Error()})
+			return
+		}
+	}
+	clearAuthCookies(ctx)
+	if user, ok := currentUser(ctx); ok {
+		audit.Record(ctx, audit.EventLogout, user.Username, "")
 	}
-	ctx.SetCookie("Authorization", "", -1, "/", domain, secure, true)
 	ctx.JSON(200, gin.H{"data": "You are logged out!"})
 }
+
+// currentUser returns the user stored in the context by RequireAuth
+func currentUser(ctx *gin.Context) (models.User, bool) {
+	value, exists := ctx.Get("user")
+	if !exists {
+		return models.User{}, false
+	}
+	user, ok := value.(models.User)
+	return user, ok
+}
+
+// UpdateUserRole lets an admin change the role of another user
+func UpdateUserRole(ctx *gin.Context) {
+	username := ctx.Param("username")
+
+	var body struct {
+		Role string
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		ctx.JSON(400, gin.H{"error": "Invalid JSON payload", "details": err.Error()})
+		return
+	}
+	if !rbac.ValidRole(body.Role) {
+		ctx.JSON(400, gin.H{"error": "Invalid role", "role": body.Role})
+		return
+	}
+
+	tx, err := inits.DB.BeginTx(ctx, nil)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer tx.Rollback()
+
+	if body.Role != rbac.RoleAdmin {
+		last, err := isLastAdmin(ctx, tx, username)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if last {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Cannot demote the last admin"})
+			return
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, "UPDATE users SET role = $1 WHERE username = $2", body.Role, username)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to update role", "details": err.Error()})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to update role", "details": err.Error()})
+		return
+	}
+	admin, _ := currentUser(ctx)
+	audit.Record(ctx, audit.EventRoleChanged, username, "role="+body.Role+" by="+admin.Username)
+	ctx.JSON(200, gin.H{"status": "Role updated successfully", "username": username, "role": body.Role})
+}
+
+// isLastAdmin reports whether username is the only admin left. It locks
+// every admin row so concurrent demotions and deletions are serialized
+// until tx ends
+func isLastAdmin(ctx *gin.Context, tx *sql.Tx, username string) (bool, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT username FROM users WHERE role = $1 FOR UPDATE", rbac.RoleAdmin)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	var admins []string
+	for rows.Next() {
+		var admin string
+		if err := rows.Scan(&admin); err != nil {
+			return false, err
+		}
+		admins = append(admins, admin)
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+	return len(admins) == 1 && admins[0] == username, nil
+}
+
+// UnlockUser lets an admin clear the failed login counter of a user
+func UnlockUser(ctx *gin.Context) {
+	username := ctx.Param("username")
+
+	if err := clearLoginFailures(ctx, usernameLockKey(username)); err != nil {
+		ctx.JSON(500, gin.H{"error": "Failed to unlock user", "details": err.Error()})
+		return
+	}
+	admin, _ := currentUser(ctx)
+	audit.Record(ctx, audit.EventAccountUnlocked, username, "by="+admin.Username)
+	ctx.JSON(200, gin.H{"status": "User unlocked successfully", "username": username})
+}
@@ -0,0 +1,49 @@
+package apikeys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// HeaderName is the request header machine clients present their key in
+const HeaderName = "X-API-Key"
+
+// Scopes an API key may be granted. Keys are limited to the cron
+// ingestion routes and can never be used for /auth or tag editing
+const (
+	ScopeCronQuestions   = "cron:questions"
+	ScopeCronSubmissions = "cron:submissions"
+)
+
+var validScopes = map[string]bool{
+	ScopeCronQuestions:   true,
+	ScopeCronSubmissions: true,
+}
+
+const keyPrefix = "rvsr_"
+
+// ValidScope reports whether scope can be granted to an API key
+func ValidScope(scope string) bool {
+	return validScopes[scope]
+}
+
+// Generate creates a new random key and returns the plaintext key,
+// its short display prefix and the hash that is stored in the database
+func Generate() (key string, prefix string, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", "", err
+	}
+	key = keyPrefix + hex.EncodeToString(buf)
+	prefix = key[:len(keyPrefix)+8]
+	return key, prefix, Hash(key), nil
+}
+
+// Hash returns the hex encoded SHA-256 of a key. Keys carry 256 bits of
+// entropy so a fast hash is enough to protect them at rest
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(key)))
+	return hex.EncodeToString(sum[:])
+}
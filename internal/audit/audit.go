@@ -0,0 +1,49 @@
+package audit
+
+import (
+	"log"
+	"reviser/internal/inits"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Security events written to auth_events
+const (
+	EventSignup          = "signup"
+	EventLoginSuccess    = "login_success"
+	EventLoginFailure    = "login_failure"
+	EventLoginLocked     = "login_locked"
+	EventMFARequired     = "mfa_required"
+	EventMFAFailure      = "mfa_failure"
+	EventMFAEnabled      = "mfa_enabled"
+	EventMFADisabled     = "mfa_disabled"
+	EventLogout          = "logout"
+	EventLogoutAll       = "logout_all"
+	EventTokenInvalid    = "token_invalid"
+	EventRefreshReuse    = "refresh_token_reuse"
+	EventPasswordChange  = "password_change"
+	EventPasswordReset   = "password_reset"
+	EventResetRequested  = "password_reset_requested"
+	EventAccountUpdated  = "account_updated"
+	EventAccountDeleted  = "account_deleted"
+	EventAccountUnlocked = "account_unlocked"
+	EventRoleChanged     = "role_changed"
+	EventAPIKeyCreated   = "api_key_created"
+	EventAPIKeyRevoked   = "api_key_revoked"
+	EventInviteCreated   = "invite_created"
+	EventInviteRevoked   = "invite_revoked"
+	EventIdentityLinked  = "identity_linked"
+)
+
+// Record stores a security event with the client IP and user agent of the
+// request. Failures are logged rather than returned so auditing never
+// breaks the request it describes
+func Record(ctx *gin.Context, event string, username string, details string) {
+	_, err := inits.DB.ExecContext(ctx,
+		`INSERT INTO auth_events (event, username, ip, user_agent, details)
+			VALUES ($1, $2, $3, $4, $5)`,
+		event, username, ctx.ClientIP(), ctx.Request.UserAgent(), details)
+	if err != nil {
+		log.Printf("Failed to record audit event %s for %q: %v", event, username, err)
+	}
+}
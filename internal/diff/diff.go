@@ -0,0 +1,269 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Op is the edit applied to a line
+type Op string
+
+const (
+	Equal  Op = "equal"
+	Delete Op = "delete"
+	Insert Op = "insert"
+)
+
+// maxEdits bounds the edit distance searched for. The search keeps one
+// int32 per diagonal and edit, about 4 MB at this bound, and inputs
+// further apart are diffed as a full replacement of their differing
+// middle section
+const maxEdits = 1024
+
+// Line is one line of a line-level diff. OldLine and NewLine are 1-based
+// and zero on the side the line does not appear on
+type Line struct {
+	Op      Op
+	OldLine int
+	NewLine int
+	Text    string
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Lines returns the line-level edit script turning a into b, a shortest
+// one found with Myers' algorithm. Within a run of changes deletions come
+// before insertions, as in diff -u
+func Lines(a, b string) []Line {
+	x, y := splitLines(a), splitLines(b)
+
+	// the common prefix and suffix are kept out of the search
+	prefix := 0
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(x)-prefix && suffix < len(y)-prefix && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+	mx, my := x[prefix:len(x)-suffix], y[prefix:len(y)-suffix]
+	ops, _ := shortestEdit(mx, my)
+
+	lines := make([]Line, 0, len(x)+len(y)-prefix-suffix)
+	oldLine, newLine := 0, 0
+	emit := func(op Op, text string) {
+		line := Line{Op: op, Text: text}
+		if op != Insert {
+			oldLine++
+			line.OldLine = oldLine
+		}
+		if op != Delete {
+			newLine++
+			line.NewLine = newLine
+		}
+		lines = append(lines, line)
+	}
+
+	for _, text := range x[:prefix] {
+		emit(Equal, text)
+	}
+	i, j := 0, 0
+	for k := 0; k < len(ops); {
+		if ops[k] == Equal {
+			emit(Equal, mx[i])
+			i, j, k = i+1, j+1, k+1
+			continue
+		}
+		deletes, inserts := 0, 0
+		for ; k < len(ops) && ops[k] != Equal; k++ {
+			if ops[k] == Delete {
+				deletes++
+			} else {
+				inserts++
+			}
+		}
+		for ; deletes > 0; deletes-- {
+			emit(Delete, mx[i])
+			i++
+		}
+		for ; inserts > 0; inserts-- {
+			emit(Insert, my[j])
+			j++
+		}
+	}
+	// without a script everything left is replaced
+	for ; i < len(mx); i++ {
+		emit(Delete, mx[i])
+	}
+	for ; j < len(my); j++ {
+		emit(Insert, my[j])
+	}
+	for _, text := range x[len(x)-suffix:] {
+		emit(Equal, text)
+	}
+	return lines
+}
+
+// shortestEdit returns a shortest edit script turning x into y with the
+// greedy algorithm of Myers (1986), or false when it takes more than
+// maxEdits insertions and deletions
+func shortestEdit(x, y []string) ([]Op, bool) {
+	n, m := len(x), len(y)
+	limit := min(n+m, maxEdits)
+	// v[offset+k] is the furthest x index reached on diagonal k = i - j
+	offset := limit + 1
+	v := make([]int32, 2*limit+3)
+	// trace[d] holds v[offset-d : offset+d+1] after d edits
+	var trace [][]int32
+	for d := 0; d <= limit; d++ {
+		done := false
+		for k := -d; k <= d; k += 2 {
+			var i int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				i = int(v[offset+k+1])
+			} else {
+				i = int(v[offset+k-1]) + 1
+			}
+			j := i - k
+			for i < n && j < m && x[i] == y[j] {
+				i, j = i+1, j+1
+			}
+			v[offset+k] = int32(i)
+			if i >= n && j >= m {
+				done = true
+				break
+			}
+		}
+		trace = append(trace, append([]int32(nil), v[offset-d:offset+d+1]...))
+		if done {
+			return backtrack(trace, n, m), true
+		}
+	}
+	return nil, false
+}
+
+// backtrack walks trace from (n, m) back to the origin and returns the
+// edit script in order
+func backtrack(trace [][]int32, n, m int) []Op {
+	var ops []Op
+	i, j := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d-1]
+		at := func(k int) int { return int(prev[k+d-1]) }
+		k := i - j
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevI := at(prevK)
+		// an insertion keeps the x index, a deletion advances it, and the
+		// snake of equal lines runs from there to (i, j)
+		op, snakeStart := Insert, prevI
+		if prevK == k-1 {
+			op, snakeStart = Delete, prevI+1
+		}
+		for ; i > snakeStart; i-- {
+			ops = append(ops, Equal)
+		}
+		ops = append(ops, op)
+		i, j = prevI, prevI-prevK
+	}
+	for ; i > 0; i-- {
+		ops = append(ops, Equal)
+	}
+	for l, r := 0, len(ops)-1; l < r; l, r = l+1, r-1 {
+		ops[l], ops[r] = ops[r], ops[l]
+	}
+	return ops
+}
+
+// hunkRange formats one side of a hunk header the way diff -u does
+func hunkRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// Unified renders lines as a unified diff with context unchanged lines
+// around each change. It returns an empty string when nothing changed
+func Unified(lines []Line, oldName, newName string, context int) string {
+	var b strings.Builder
+	oldSeen, newSeen := 0, 0
+	for i := 0; i < len(lines); {
+		if lines[i].Op == Equal {
+			oldSeen++
+			newSeen++
+			i++
+			continue
+		}
+		if b.Len() == 0 {
+			fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+		}
+
+		start := max(0, i-context)
+		// changes separated by at most 2*context unchanged lines share a hunk
+		end := i
+		for end < len(lines) {
+			if lines[end].Op != Equal {
+				end++
+				continue
+			}
+			run := end
+			for run < len(lines) && lines[run].Op == Equal {
+				run++
+			}
+			if run == len(lines) || run-end > 2*context {
+				end = min(len(lines), end+context)
+				break
+			}
+			end = run
+		}
+
+		oldStart, newStart := oldSeen-(i-start), newSeen-(i-start)
+		oldCount, newCount := 0, 0
+		for _, line := range lines[start:end] {
+			if line.Op != Insert {
+				oldCount++
+			}
+			if line.Op != Delete {
+				newCount++
+			}
+		}
+		if oldCount > 0 {
+			oldStart++
+		}
+		if newCount > 0 {
+			newStart++
+		}
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))
+		for _, line := range lines[start:end] {
+			switch line.Op {
+			case Equal:
+				b.WriteString(" ")
+			case Delete:
+				b.WriteString("-")
+			case Insert:
+				b.WriteString("+")
+			}
+			b.WriteString(line.Text)
+			b.WriteString("\n")
+		}
+
+		oldSeen += oldCount - (i - start)
+		newSeen += newCount - (i - start)
+		i = end
+	}
+	return b.String()
+}
@@ -0,0 +1,163 @@
+package diff
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestUnifiedMatchesDiff compares Unified with GNU diff output. Each case
+// has name.old and name.new in testdata, and name.diff was made with
+//
+//	diff -U<context> --label old --label new name.old name.new > name.diff
+func TestUnifiedMatchesDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		context int
+	}{
+		{"identical", 3},
+		{"single_change", 3},
+		// 6 unchanged lines between changes still share one hunk
+		{"gap_exactly_2x_context", 3},
+		{"gap_over_2x_context", 3},
+		{"empty_old", 3},
+		{"empty_new", 3},
+		// an empty side is numbered by the line before it: -5,0
+		{"insert_no_context", 0},
+		{"delete_no_context", 0},
+		{"change_first_and_last", 3},
+		{"rewritten_solution", 3},
+		{"replace_block_one_context", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			read := func(ext string) string {
+				data, err := os.ReadFile(filepath.Join("testdata", tt.name+ext))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return string(data)
+			}
+			got := Unified(Lines(read(".old"), read(".new")), "old", "new", tt.context)
+			if want := read(".diff"); got != want {
+				t.Errorf("Unified =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestLinesNumbersBothSides(t *testing.T) {
+	got := Lines("a\nb\nc\n", "a\nx\nc\nd\n")
+	want := []Line{
+		{Equal, 1, 1, "a"},
+		{Delete, 2, 0, "b"},
+		{Insert, 0, 2, "x"},
+		{Equal, 3, 3, "c"},
+		{Insert, 0, 4, "d"},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Lines = %v, want %v", got, want)
+	}
+	if got := Lines("a\r\nb", "a\nb\n"); len(got) != 2 || got[0].Op != Equal || got[1].Op != Equal {
+		t.Errorf("CRLF and a missing final newline should not count as changes: %v", got)
+	}
+}
+
+// interleaved returns pairs common and changed lines, the changed ones
+// tagged with side
+func interleaved(pairs int, side string) string {
+	var b strings.Builder
+	for i := 0; i < pairs; i++ {
+		fmt.Fprintf(&b, "common %d\n%s %d\n", i, side, i)
+	}
+	return b.String()
+}
+
+func count(lines []Line, op Op) int {
+	n := 0
+	for _, line := range lines {
+		if line.Op == op {
+			n++
+		}
+	}
+	return n
+}
+
+func TestLinesEditBound(t *testing.T) {
+	// each changed line takes a deletion and an insertion
+	within := maxEdits / 2
+	lines := Lines(interleaved(within, "old"), interleaved(within, "new"))
+	if got := count(lines, Equal); got != within {
+		t.Errorf("within maxEdits: %d equal lines, want %d", got, within)
+	}
+
+	// beyond the bound the differing middle is replaced as a whole: the
+	// first common line is a shared prefix and everything else changes
+	beyond := maxEdits/2 + 1
+	lines = Lines(interleaved(beyond, "old"), interleaved(beyond, "new"))
+	if got := count(lines, Equal); got != 1 {
+		t.Errorf("beyond maxEdits: %d equal lines, want 1", got)
+	}
+	if got := count(lines, Delete); got != 2*beyond-1 {
+		t.Errorf("beyond maxEdits: %d deletions, want %d", got, 2*beyond-1)
+	}
+	for i, line := range lines[1 : beyond*2] {
+		if line.Op != Delete {
+			t.Fatalf("line %d is %s, want all deletions before the insertions", i+1, line.Op)
+		}
+	}
+}
+
+func TestShortestEditIsMinimal(t *testing.T) {
+	tests := []struct {
+		x, y  string
+		edits int
+	}{
+		{"abcabba", "cbabac", 5},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"abc", "abc", 0},
+		{"abcd", "acbd", 2},
+	}
+	for _, tt := range tests {
+		x, y := strings.Split(tt.x, ""), strings.Split(tt.y, "")
+		ops, ok := shortestEdit(x, y)
+		if !ok {
+			t.Fatalf("shortestEdit(%q, %q) gave up", tt.x, tt.y)
+		}
+		if got := len(ops) - count(linesOf(ops), Equal); got != tt.edits {
+			t.Errorf("shortestEdit(%q, %q) = %v, %d edits, want %d", tt.x, tt.y, ops, got, tt.edits)
+		}
+		// replaying the script must turn x into y
+		var out []string
+		i, j := 0, 0
+		for _, op := range ops {
+			switch op {
+			case Equal:
+				if x[i] != y[j] {
+					t.Fatalf("shortestEdit(%q, %q) keeps %q as %q", tt.x, tt.y, x[i], y[j])
+				}
+				out = append(out, x[i])
+				i, j = i+1, j+1
+			case Delete:
+				i++
+			case Insert:
+				out = append(out, y[j])
+				j++
+			}
+		}
+		if strings.Join(out, "") != strings.Join(y, "") || i != len(x) {
+			t.Errorf("replaying shortestEdit(%q, %q) gives %q", tt.x, tt.y, strings.Join(out, ""))
+		}
+	}
+}
+
+func linesOf(ops []Op) []Line {
+	lines := make([]Line, len(ops))
+	for i, op := range ops {
+		lines[i].Op = op
+	}
+	return lines
+}
@@ -0,0 +1,29 @@
+package inits
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"reviser/internal/tokens"
+	"syscall"
+)
+
+func KeyringInit() {
+	if err := tokens.LoadKeyring(); err != nil {
+		log.Fatalf("Failed to load JWT keyring: %v", err)
+	}
+	log.Println("JWT keyring loaded successfully")
+
+	// SIGHUP reloads the keyring file so keys can be rotated without a restart
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			if err := tokens.LoadKeyring(); err != nil {
+				log.Printf("Failed to reload JWT keyring, keeping the current keys: %v", err)
+				continue
+			}
+			log.Println("JWT keyring reloaded successfully")
+		}
+	}()
+}
@@ -0,0 +1,65 @@
+package inits
+
+import (
+	"embed"
+	"io/fs"
+	"log"
+	"sort"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// RunMigrations applies every embedded SQL migration that has not been
+// recorded in schema_migrations yet, in file name order
+func RunMigrations() {
+	_, err := DB.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    TEXT PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		log.Fatalf("Failed to create schema_migrations table: %v", err)
+	}
+
+	names, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		log.Fatalf("Failed to list migrations: %v", err)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		version := strings.TrimSuffix(strings.TrimPrefix(name, "migrations/"), ".sql")
+
+		var applied bool
+		err := DB.QueryRow("SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)", version).Scan(&applied)
+		if err != nil {
+			log.Fatalf("Failed to check migration %s: %v", version, err)
+		}
+		if applied {
+			continue
+		}
+
+		script, err := migrationFiles.ReadFile(name)
+		if err != nil {
+			log.Fatalf("Failed to read migration %s: %v", version, err)
+		}
+
+		tx, err := DB.Begin()
+		if err != nil {
+			log.Fatalf("Failed to start migration %s: %v", version, err)
+		}
+		if _, err := tx.Exec(string(script)); err != nil {
+			tx.Rollback()
+			log.Fatalf("Failed to apply migration %s: %v", version, err)
+		}
+		if _, err := tx.Exec("INSERT INTO schema_migrations (version) VALUES ($1)", version); err != nil {
+			tx.Rollback()
+			log.Fatalf("Failed to record migration %s: %v", version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			log.Fatalf("Failed to commit migration %s: %v", version, err)
+		}
+		log.Printf("Applied migration %s", version)
+	}
+}
@@ -0,0 +1,26 @@
+-- Baseline schema used by the original controllers. Every statement is
+-- idempotent so it is safe to apply against an existing deployment.
+CREATE TABLE IF NOT EXISTS users (
+	name     TEXT NOT NULL DEFAULT '',
+	username TEXT NOT NULL,
+	password TEXT NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS users_username_key ON users (username);
+
+CREATE TABLE IF NOT EXISTS leetcode_questions (
+	slug        TEXT PRIMARY KEY,
+	title       TEXT NOT NULL DEFAULT '',
+	description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS leetcode_submissions (
+	submission_id BIGINT PRIMARY KEY,
+	question_slug TEXT NOT NULL REFERENCES leetcode_questions (slug),
+	code          TEXT NOT NULL DEFAULT '',
+	submitted_at  TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS question_tags (
+	slug TEXT PRIMARY KEY,
+	tags JSONB NOT NULL DEFAULT '[]'
+);
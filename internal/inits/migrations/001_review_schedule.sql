@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS review_schedule (
+	username         TEXT NOT NULL REFERENCES users (username) ON UPDATE CASCADE ON DELETE CASCADE,
+	question_slug    TEXT NOT NULL REFERENCES leetcode_questions (slug) ON DELETE CASCADE,
+	ease_factor      DOUBLE PRECISION NOT NULL DEFAULT 2.5,
+	interval_days    INTEGER NOT NULL DEFAULT 0,
+	repetitions      INTEGER NOT NULL DEFAULT 0,
+	due_at           TIMESTAMPTZ NOT NULL,
+	last_reviewed_at TIMESTAMPTZ,
+	PRIMARY KEY (username, question_slug)
+);
+CREATE INDEX IF NOT EXISTS review_schedule_due_idx ON review_schedule (username, due_at);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS review_log (
+	review_id          BIGSERIAL PRIMARY KEY,
+	username           TEXT NOT NULL REFERENCES users (username) ON UPDATE CASCADE ON DELETE CASCADE,
+	question_slug      TEXT NOT NULL REFERENCES leetcode_questions (slug) ON DELETE CASCADE,
+	grade              SMALLINT NOT NULL CHECK (grade BETWEEN 0 AND 5),
+	time_spent_seconds INTEGER NOT NULL DEFAULT 0 CHECK (time_spent_seconds >= 0),
+	notes              TEXT NOT NULL DEFAULT '',
+	reviewed_at        TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS review_log_user_slug_idx ON review_log (username, question_slug, reviewed_at DESC);
@@ -0,0 +1,17 @@
+-- Submissions and tags are owned by the user that ingested or edited them.
+ALTER TABLE leetcode_submissions
+	ADD COLUMN IF NOT EXISTS username TEXT REFERENCES users (username) ON UPDATE CASCADE ON DELETE CASCADE;
+CREATE INDEX IF NOT EXISTS leetcode_submissions_username_idx ON leetcode_submissions (username, submitted_at DESC);
+
+ALTER TABLE question_tags
+	ADD COLUMN IF NOT EXISTS username TEXT REFERENCES users (username) ON UPDATE CASCADE ON DELETE CASCADE;
+ALTER TABLE question_tags DROP CONSTRAINT IF EXISTS question_tags_pkey;
+CREATE UNIQUE INDEX IF NOT EXISTS question_tags_username_slug_key ON question_tags (username, slug);
+
+-- Rows created before ownership existed can only be attributed automatically
+-- on single-user deployments; otherwise they stay unowned until claimed with
+-- an UPDATE by the operator.
+UPDATE leetcode_submissions SET username = (SELECT username FROM users)
+	WHERE username IS NULL AND (SELECT COUNT(*) FROM users) = 1;
+UPDATE question_tags SET username = (SELECT username FROM users)
+	WHERE username IS NULL AND (SELECT COUNT(*) FROM users) = 1;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+	key_id       BIGSERIAL PRIMARY KEY,
+	username     TEXT NOT NULL REFERENCES users (username) ON UPDATE CASCADE ON DELETE CASCADE,
+	name         TEXT NOT NULL DEFAULT '',
+	prefix       TEXT NOT NULL,
+	key_hash     TEXT NOT NULL UNIQUE,
+	scopes       JSONB NOT NULL DEFAULT '[]',
+	created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+	last_used_at TIMESTAMPTZ,
+	revoked_at   TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS api_keys_username_idx ON api_keys (username);
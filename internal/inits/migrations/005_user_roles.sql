@@ -0,0 +1,8 @@
+-- Existing accounts could already do everything, so they keep full access.
+-- New signups default to read-only.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT;
+UPDATE users SET role = 'admin' WHERE role IS NULL;
+ALTER TABLE users ALTER COLUMN role SET DEFAULT 'viewer';
+ALTER TABLE users ALTER COLUMN role SET NOT NULL;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('admin', 'editor', 'viewer'));
@@ -0,0 +1,8 @@
+-- Failed login tracking, keyed by "user:<username>" or "ip:<address>".
+-- Usernames are tracked whether or not the account exists.
+CREATE TABLE IF NOT EXISTS login_failures (
+	key             TEXT PRIMARY KEY,
+	failures        INTEGER NOT NULL DEFAULT 0,
+	last_failure_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	locked_until    TIMESTAMPTZ
+);
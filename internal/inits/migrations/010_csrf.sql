@@ -0,0 +1,3 @@
+-- Synchronizer CSRF token per session. Sessions created before this
+-- migration get one on their next refresh.
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS csrf_token TEXT NOT NULL DEFAULT '';
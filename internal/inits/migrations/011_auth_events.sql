@@ -0,0 +1,13 @@
+-- Security audit trail. username is not a foreign key so events outlive
+-- renamed or deleted accounts and can name usernames that never existed.
+CREATE TABLE IF NOT EXISTS auth_events (
+	event_id   BIGSERIAL PRIMARY KEY,
+	event      TEXT NOT NULL,
+	username   TEXT NOT NULL DEFAULT '',
+	ip         TEXT NOT NULL DEFAULT '',
+	user_agent TEXT NOT NULL DEFAULT '',
+	details    TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS auth_events_username_idx ON auth_events (username, created_at DESC);
+CREATE INDEX IF NOT EXISTS auth_events_created_at_idx ON auth_events (created_at DESC);
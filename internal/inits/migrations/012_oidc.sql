@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS oidc_states (
+	state         TEXT PRIMARY KEY,
+	nonce         TEXT NOT NULL,
+	code_verifier TEXT NOT NULL,
+	created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Links an identity at an OIDC provider to a local account.
+CREATE TABLE IF NOT EXISTS user_identities (
+	issuer     TEXT NOT NULL,
+	subject    TEXT NOT NULL,
+	username   TEXT NOT NULL REFERENCES users (username) ON UPDATE CASCADE ON DELETE CASCADE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (issuer, subject)
+);
+CREATE INDEX IF NOT EXISTS user_identities_username_idx ON user_identities (username);
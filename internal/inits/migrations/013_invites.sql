@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS invites (
+	invite_id  BIGSERIAL PRIMARY KEY,
+	code_hash  TEXT NOT NULL UNIQUE,
+	prefix     TEXT NOT NULL,
+	note       TEXT NOT NULL DEFAULT '',
+	created_by TEXT NOT NULL DEFAULT '',
+	max_uses   INTEGER NOT NULL DEFAULT 1 CHECK (max_uses > 0),
+	uses       INTEGER NOT NULL DEFAULT 0,
+	expires_at TIMESTAMPTZ NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	revoked_at TIMESTAMPTZ
+);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS invite_id BIGINT REFERENCES invites (invite_id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS users_invite_id_idx ON users (invite_id);
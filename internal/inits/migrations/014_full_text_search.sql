@@ -0,0 +1,11 @@
+ALTER TABLE leetcode_questions ADD COLUMN IF NOT EXISTS search_vector tsvector
+	GENERATED ALWAYS AS (
+		setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+		setweight(to_tsvector('english', coalesce(description, '')), 'B')
+	) STORED;
+CREATE INDEX IF NOT EXISTS leetcode_questions_search_idx ON leetcode_questions USING GIN (search_vector);
+
+-- Code is indexed with the simple configuration so identifiers are not stemmed.
+ALTER TABLE leetcode_submissions ADD COLUMN IF NOT EXISTS code_vector tsvector
+	GENERATED ALWAYS AS (to_tsvector('simple', coalesce(code, ''))) STORED;
+CREATE INDEX IF NOT EXISTS leetcode_submissions_code_search_idx ON leetcode_submissions USING GIN (code_vector);
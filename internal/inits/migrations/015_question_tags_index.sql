@@ -0,0 +1,2 @@
+-- jsonb_ops supports the ?| and ?& operators used to filter by tags.
+CREATE INDEX IF NOT EXISTS question_tags_tags_idx ON question_tags USING GIN (tags);
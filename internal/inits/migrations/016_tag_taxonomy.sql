@@ -0,0 +1,63 @@
+-- Tags become a shared taxonomy. Names are unique regardless of case and
+-- aliases (stored lower case) resolve to their canonical tag.
+CREATE TABLE IF NOT EXISTS tags (
+	tag_id     BIGSERIAL PRIMARY KEY,
+	name       TEXT NOT NULL CHECK (btrim(name) <> ''),
+	parent_id  BIGINT REFERENCES tags (tag_id) ON DELETE SET NULL,
+	color      TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE UNIQUE INDEX IF NOT EXISTS tags_name_key ON tags (lower(name));
+CREATE INDEX IF NOT EXISTS tags_parent_id_idx ON tags (parent_id);
+
+CREATE TABLE IF NOT EXISTS tag_aliases (
+	alias  TEXT PRIMARY KEY CHECK (alias = lower(alias)),
+	tag_id BIGINT NOT NULL REFERENCES tags (tag_id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS tag_aliases_tag_id_idx ON tag_aliases (tag_id);
+
+-- every lower case name a tag can be referred to by
+CREATE OR REPLACE VIEW tag_names AS
+	SELECT tag_id, lower(name) AS name FROM tags
+	UNION ALL
+	SELECT tag_id, alias FROM tag_aliases;
+
+-- every tag paired with itself and each of its ancestors
+CREATE OR REPLACE VIEW tag_closure AS
+	WITH RECURSIVE closure (ancestor_id, tag_id) AS (
+		SELECT tag_id, tag_id FROM tags
+		UNION
+		SELECT c.ancestor_id, t.tag_id
+		FROM closure c
+		JOIN tags t ON t.parent_id = c.tag_id
+	)
+	SELECT ancestor_id, tag_id FROM closure;
+
+-- question_tags keeps one row per tagged question of a user, the tags
+-- themselves move to question_tag_links
+CREATE TABLE IF NOT EXISTS question_tag_links (
+	username TEXT,
+	slug     TEXT NOT NULL,
+	tag_id   BIGINT NOT NULL REFERENCES tags (tag_id) ON DELETE CASCADE,
+	FOREIGN KEY (username, slug) REFERENCES question_tags (username, slug) ON UPDATE CASCADE ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS question_tag_links_key ON question_tag_links (username, slug, tag_id);
+CREATE INDEX IF NOT EXISTS question_tag_links_tag_id_idx ON question_tag_links (tag_id, username);
+
+-- spellings differing only in case or surrounding spaces become one tag
+INSERT INTO tags (name)
+	SELECT DISTINCT ON (lower(btrim(e.tag))) btrim(e.tag)
+	FROM question_tags qt
+	CROSS JOIN LATERAL jsonb_array_elements_text(qt.tags) AS e (tag)
+	WHERE btrim(e.tag) <> ''
+	ORDER BY lower(btrim(e.tag)), btrim(e.tag)
+	ON CONFLICT DO NOTHING;
+
+INSERT INTO question_tag_links (username, slug, tag_id)
+	SELECT DISTINCT qt.username, qt.slug, t.tag_id
+	FROM question_tags qt
+	CROSS JOIN LATERAL jsonb_array_elements_text(qt.tags) AS e (tag)
+	JOIN tags t ON lower(t.name) = lower(btrim(e.tag));
+
+DROP INDEX IF EXISTS question_tags_tags_idx;
+ALTER TABLE question_tags DROP COLUMN IF EXISTS tags;
@@ -0,0 +1,6 @@
+-- Submission lists page by (submitted_at, submission_id), newest first.
+DROP INDEX IF EXISTS leetcode_submissions_username_idx;
+CREATE INDEX IF NOT EXISTS leetcode_submissions_username_keyset_idx
+	ON leetcode_submissions (username, submitted_at DESC, submission_id DESC);
+CREATE INDEX IF NOT EXISTS leetcode_submissions_slug_keyset_idx
+	ON leetcode_submissions (username, question_slug, submitted_at DESC, submission_id DESC);
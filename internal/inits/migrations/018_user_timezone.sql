@@ -0,0 +1,2 @@
+-- IANA zone used to interpret calendar days when a request has no tz parameter.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone TEXT NOT NULL DEFAULT 'UTC';
@@ -0,0 +1,11 @@
+-- Judge metadata of a submission. Rows ingested before it existed are
+-- assumed accepted, since only accepted submissions were scraped.
+ALTER TABLE leetcode_submissions
+	ADD COLUMN IF NOT EXISTS language           TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS status             TEXT NOT NULL DEFAULT 'accepted',
+	ADD COLUMN IF NOT EXISTS runtime_ms         INTEGER CHECK (runtime_ms >= 0),
+	ADD COLUMN IF NOT EXISTS memory_mb          DOUBLE PRECISION CHECK (memory_mb >= 0),
+	ADD COLUMN IF NOT EXISTS runtime_percentile DOUBLE PRECISION CHECK (runtime_percentile BETWEEN 0 AND 100),
+	ADD COLUMN IF NOT EXISTS memory_percentile  DOUBLE PRECISION CHECK (memory_percentile BETWEEN 0 AND 100);
+CREATE INDEX IF NOT EXISTS leetcode_submissions_language_status_idx
+	ON leetcode_submissions (username, language, status);
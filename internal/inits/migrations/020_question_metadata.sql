@@ -0,0 +1,12 @@
+-- Platform metadata of a question. Topics are the platform's official
+-- topic tags, separate from the tags users assign themselves.
+ALTER TABLE leetcode_questions
+	ADD COLUMN IF NOT EXISTS difficulty      TEXT NOT NULL DEFAULT '' CHECK (difficulty IN ('', 'Easy', 'Medium', 'Hard')),
+	ADD COLUMN IF NOT EXISTS platform_id     INTEGER,
+	ADD COLUMN IF NOT EXISTS url             TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS is_premium      BOOLEAN NOT NULL DEFAULT false,
+	ADD COLUMN IF NOT EXISTS topics          JSONB NOT NULL DEFAULT '[]',
+	ADD COLUMN IF NOT EXISTS hints           JSONB NOT NULL DEFAULT '[]',
+	ADD COLUMN IF NOT EXISTS similar_slugs   JSONB NOT NULL DEFAULT '[]',
+	ADD COLUMN IF NOT EXISTS acceptance_rate DOUBLE PRECISION CHECK (acceptance_rate BETWEEN 0 AND 100);
+CREATE INDEX IF NOT EXISTS leetcode_questions_difficulty_idx ON leetcode_questions (difficulty);
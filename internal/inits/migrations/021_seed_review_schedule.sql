@@ -0,0 +1,15 @@
+-- Review schedules used to be seeded from every submission on each read of
+-- the due list. New accepted submissions now seed their own entry, so seed
+-- the existing ones once and drop untouched entries of unsolved questions.
+DELETE FROM review_schedule r
+	WHERE r.last_reviewed_at IS NULL
+		AND NOT EXISTS (
+			SELECT 1 FROM leetcode_submissions s
+			WHERE s.username = r.username AND s.question_slug = r.question_slug AND s.status = 'accepted'
+		);
+INSERT INTO review_schedule (username, question_slug, ease_factor, due_at)
+	SELECT username, question_slug, 2.5, MAX(submitted_at) + INTERVAL '1 day'
+	FROM leetcode_submissions
+	WHERE status = 'accepted'
+	GROUP BY username, question_slug
+	ON CONFLICT (username, question_slug) DO NOTHING;
@@ -0,0 +1,7 @@
+-- Fixed window request counters for unauthenticated endpoints, keyed by
+-- "<endpoint>:ip:<address>" or "<endpoint>:email:<address>".
+CREATE TABLE IF NOT EXISTS rate_limits (
+	key          TEXT PRIMARY KEY,
+	hits         INTEGER NOT NULL DEFAULT 0,
+	window_start TIMESTAMPTZ NOT NULL DEFAULT now()
+);
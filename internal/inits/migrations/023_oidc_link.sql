@@ -0,0 +1,5 @@
+-- A flow started by a signed in user links the identity to that account
+-- instead of signing in. Expired states are purged by created_at.
+ALTER TABLE oidc_states
+	ADD COLUMN IF NOT EXISTS link_username TEXT REFERENCES users (username) ON UPDATE CASCADE ON DELETE CASCADE;
+CREATE INDEX IF NOT EXISTS oidc_states_created_at_idx ON oidc_states (created_at);
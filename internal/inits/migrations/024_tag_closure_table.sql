@@ -0,0 +1,66 @@
+-- tag_closure was a recursive view walking the whole taxonomy on every tag
+-- filter. It becomes a table kept current by triggers on tags: a new tag
+-- copies its parent's ancestors, a parent change rebuilds the table and a
+-- deleted tag cascades.
+DROP VIEW IF EXISTS tag_closure;
+
+CREATE TABLE IF NOT EXISTS tag_closure (
+	ancestor_id BIGINT NOT NULL REFERENCES tags (tag_id) ON DELETE CASCADE,
+	tag_id      BIGINT NOT NULL REFERENCES tags (tag_id) ON DELETE CASCADE,
+	PRIMARY KEY (ancestor_id, tag_id)
+);
+CREATE INDEX IF NOT EXISTS tag_closure_tag_id_idx ON tag_closure (tag_id);
+
+CREATE OR REPLACE FUNCTION rebuild_tag_closure() RETURNS trigger AS $$
+BEGIN
+	-- concurrent rebuilds would insert the same rows
+	LOCK TABLE tag_closure IN EXCLUSIVE MODE;
+	DELETE FROM tag_closure;
+	INSERT INTO tag_closure (ancestor_id, tag_id)
+		WITH RECURSIVE closure (ancestor_id, tag_id) AS (
+			SELECT tag_id, tag_id FROM tags
+			UNION
+			SELECT c.ancestor_id, t.tag_id
+			FROM closure c
+			JOIN tags t ON t.parent_id = c.tag_id
+		)
+		SELECT ancestor_id, tag_id FROM closure;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION insert_tag_closure() RETURNS trigger AS $$
+BEGIN
+	INSERT INTO tag_closure (ancestor_id, tag_id)
+		SELECT NEW.tag_id, NEW.tag_id
+		UNION
+		SELECT ancestor_id, NEW.tag_id FROM tag_closure WHERE tag_id = NEW.parent_id
+		ON CONFLICT DO NOTHING;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS tags_closure_insert ON tags;
+CREATE TRIGGER tags_closure_insert AFTER INSERT ON tags
+	FOR EACH ROW EXECUTE FUNCTION insert_tag_closure();
+
+-- also fires for the ON DELETE SET NULL of a deleted parent
+DROP TRIGGER IF EXISTS tags_closure_reparent ON tags;
+CREATE TRIGGER tags_closure_reparent AFTER UPDATE OF parent_id ON tags
+	FOR EACH STATEMENT EXECUTE FUNCTION rebuild_tag_closure();
+
+INSERT INTO tag_closure (ancestor_id, tag_id)
+	WITH RECURSIVE closure (ancestor_id, tag_id) AS (
+		SELECT tag_id, tag_id FROM tags
+		UNION
+		SELECT c.ancestor_id, t.tag_id
+		FROM closure c
+		JOIN tags t ON t.parent_id = c.tag_id
+	)
+	SELECT ancestor_id, tag_id FROM closure;
+
+-- colors are #rrggbb or empty
+UPDATE tags SET color = '' WHERE color !~ '^#[0-9a-fA-F]{6}$';
+UPDATE tags SET color = lower(color);
+ALTER TABLE tags DROP CONSTRAINT IF EXISTS tags_color_check;
+ALTER TABLE tags ADD CONSTRAINT tags_color_check CHECK (color = '' OR color ~ '^#[0-9a-f]{6}$');
@@ -0,0 +1,14 @@
+-- Review, audit and tag lists page by keyset like the submission lists.
+DROP INDEX IF EXISTS review_schedule_due_idx;
+CREATE INDEX IF NOT EXISTS review_schedule_due_keyset_idx
+	ON review_schedule (username, due_at, question_slug);
+DROP INDEX IF EXISTS review_log_user_slug_idx;
+CREATE INDEX IF NOT EXISTS review_log_keyset_idx
+	ON review_log (username, question_slug, reviewed_at DESC, review_id DESC);
+DROP INDEX IF EXISTS auth_events_username_idx;
+DROP INDEX IF EXISTS auth_events_created_at_idx;
+CREATE INDEX IF NOT EXISTS auth_events_username_keyset_idx
+	ON auth_events (username, created_at DESC, event_id DESC);
+CREATE INDEX IF NOT EXISTS auth_events_keyset_idx
+	ON auth_events (created_at DESC, event_id DESC);
+CREATE INDEX IF NOT EXISTS tags_name_keyset_idx ON tags (name, tag_id);
@@ -0,0 +1,6 @@
+-- API key and invite lists page by keyset too.
+DROP INDEX IF EXISTS api_keys_username_idx;
+CREATE INDEX IF NOT EXISTS api_keys_keyset_idx
+	ON api_keys (username, created_at DESC, key_id DESC);
+CREATE INDEX IF NOT EXISTS invites_keyset_idx
+	ON invites (created_at DESC, invite_id DESC);
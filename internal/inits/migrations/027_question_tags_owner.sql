@@ -0,0 +1,32 @@
+-- 003 left question_tags.username nullable, so tags from before ownership
+-- stayed unowned on deployments with several users. They go to the first
+-- admin (by username), or the first user when there is no admin, merging
+-- with tags that user already has for the same question. Without any user
+-- there is nobody to give them to and they are dropped. Assign them to
+-- someone else first by setting question_tags.username and
+-- question_tag_links.username before upgrading.
+INSERT INTO question_tags (username, slug)
+	SELECT owner.username, qt.slug
+	FROM question_tags qt
+	CROSS JOIN (SELECT username FROM users ORDER BY role = 'admin' DESC, username LIMIT 1) owner
+	WHERE qt.username IS NULL
+	ON CONFLICT (username, slug) DO NOTHING;
+
+-- links with a NULL username are not covered by the foreign key and so
+-- do not follow the rows above
+INSERT INTO question_tag_links (username, slug, tag_id)
+	SELECT owner.username, l.slug, l.tag_id
+	FROM question_tag_links l
+	CROSS JOIN (SELECT username FROM users ORDER BY role = 'admin' DESC, username LIMIT 1) owner
+	WHERE l.username IS NULL
+	ON CONFLICT (username, slug, tag_id) DO NOTHING;
+
+DELETE FROM question_tag_links WHERE username IS NULL;
+DELETE FROM question_tags WHERE username IS NULL;
+
+ALTER TABLE question_tag_links ALTER COLUMN username SET NOT NULL;
+ALTER TABLE question_tags ALTER COLUMN username SET NOT NULL;
+-- the unique index becomes the primary key, keeping the foreign key of
+-- question_tag_links that depends on it
+ALTER TABLE question_tags
+	ADD CONSTRAINT question_tags_pkey PRIMARY KEY USING INDEX question_tags_username_slug_key;
@@ -0,0 +1,17 @@
+package inits
+
+import (
+	"log"
+	"reviser/internal/notify"
+)
+
+var Notifier notify.Notifier
+
+func NotifierInit() {
+	notifier, err := notify.FromEnv()
+	if err != nil {
+		log.Fatalf("Failed to initialize notifier: %v", err)
+	}
+	Notifier = notifier
+	log.Printf("Notifier initialized: %T", Notifier)
+}
@@ -0,0 +1,16 @@
+package inits
+
+import (
+	"log"
+	"reviser/internal/oidc"
+)
+
+// OIDC is nil when no OpenID Connect provider is configured
+var OIDC *oidc.Provider
+
+func OIDCInit() {
+	OIDC = oidc.FromEnv()
+	if OIDC != nil {
+		log.Printf("OIDC provider configured: %s", OIDC.Issuer)
+	}
+}
@@ -0,0 +1,134 @@
+package judge
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Languages use short canonical names, LeetCode's language slugs and
+// common spellings are mapped onto them by NormalizeLanguage
+const (
+	LanguageC          = "c"
+	LanguageCPP        = "cpp"
+	LanguageCSharp     = "csharp"
+	LanguageGo         = "go"
+	LanguageJava       = "java"
+	LanguageJavaScript = "javascript"
+	LanguageKotlin     = "kotlin"
+	LanguagePHP        = "php"
+	LanguagePython     = "python"
+	LanguageRuby       = "ruby"
+	LanguageRust       = "rust"
+	LanguageScala      = "scala"
+	LanguageSQL        = "sql"
+	LanguageSwift      = "swift"
+	LanguageTypeScript = "typescript"
+)
+
+var languageAliases = map[string]string{
+	"c++":        LanguageCPP,
+	"c#":         LanguageCSharp,
+	"cs":         LanguageCSharp,
+	"golang":     LanguageGo,
+	"js":         LanguageJavaScript,
+	"node":       LanguageJavaScript,
+	"kt":         LanguageKotlin,
+	"py":         LanguagePython,
+	"python2":    LanguagePython,
+	"python3":    LanguagePython,
+	"rb":         LanguageRuby,
+	"rs":         LanguageRust,
+	"ts":         LanguageTypeScript,
+	"mysql":      LanguageSQL,
+	"mssql":      LanguageSQL,
+	"oraclesql":  LanguageSQL,
+	"postgresql": LanguageSQL,
+	"pythondata": LanguagePython,
+}
+
+// NormalizeLanguage lower cases a language name and maps known aliases
+// to their canonical name. Other names are kept as given
+func NormalizeLanguage(language string) string {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if canonical, ok := languageAliases[language]; ok {
+		return canonical
+	}
+	return language
+}
+
+type languageSignal struct {
+	language string
+	pattern  *regexp.Regexp
+	weight   int
+}
+
+func signal(language string, weight int, pattern string) languageSignal {
+	return languageSignal{language: language, pattern: regexp.MustCompile(pattern), weight: weight}
+}
+
+// signals are matched against LeetCode style solutions, which are mostly
+// a Solution class or a top level function in the judge's template
+var signals = []languageSignal{
+	signal(LanguageCPP, 3, `#include\s*<|std::|public:|vector<|unordered_map<`),
+	signal(LanguageCPP, 1, `->\w|\bauto\b`),
+	signal(LanguageC, 3, `\b\w+Size\b|\bmalloc\(|\*returnSize`),
+	signal(LanguageC, 1, `#include\s*<std(io|lib)\.h>`),
+	// Java and C# share most syntax, so the method name case of their
+	// templates only counts as a hint
+	signal(LanguageJava, 3, `\bimport java\.|\bSystem\.out\.|\bString\[\]|<(Integer|Character|Boolean|Long|String)\b|\b(public|private|static) boolean\b|\.charAt\(`),
+	signal(LanguageJava, 1, `\bnew (int|long|boolean|char)\[|\bHashMap<|\bArrayList<|\.length\b`),
+	signal(LanguageJava, 1, `\bpublic (int|long|boolean|String|List<\w+>|void|int\[\])\s+[a-z]\w*\(`),
+	signal(LanguageCSharp, 3, `\busing System|\bDictionary<|\bIList<|\b(public|private|static) (bool|string)\b|\bout (int|var) \w`),
+	signal(LanguageCSharp, 1, `\.Length\b|\.Count\b|\bvar \w+ = new\b`),
+	signal(LanguageCSharp, 1, `\bpublic (int|long|bool|string|IList<\w+>|void|int\[\])\s+[A-Z]\w*\(`),
+	signal(LanguageGo, 3, `\bfunc \w+\([^)]*\b\w+ \[\]?\w+|:=|\bpackage main\b`),
+	signal(LanguageGo, 1, `\bmake\(|\bfor _, |\blen\(\w+\)`),
+	signal(LanguagePython, 3, `\bdef \w+\(self\b|\bclass Solution(\(object\))?:|\bList\[\w+\]`),
+	signal(LanguagePython, 1, `(?m)\belif\b|\bNone\b|\bTrue\b|\bFalse\b|:\s*$`),
+	// plain JavaScript is also TypeScript, only the JSDoc of the template
+	// tells them apart
+	signal(LanguageJavaScript, 3, `@param \{number(\[\])?\}|@return \{`),
+	signal(LanguageJavaScript, 1, `===|\bconst \w+ = |\blet \w+ = |=>|\bvar \w+ = function\s*\(`),
+	signal(LanguageTypeScript, 3, `\bfunction \w+\([^)]*:\s*(number|string|boolean)|\):\s*(number|string|boolean|void)(\[\])*\s*\{`),
+	signal(LanguageRust, 3, `\bimpl Solution\b|\bfn \w+\(|\blet mut\b|\bVec<`),
+	signal(LanguageRust, 1, `\busize\b|\bi32\b|&str\b`),
+	signal(LanguageKotlin, 3, `\bfun \w+\(|\bIntArray\b`),
+	signal(LanguageKotlin, 1, `\bval \w+`),
+	signal(LanguageSwift, 3, `\bfunc \w+\(_ |->\s*\[?(Int|Bool|String|Double)\]?`),
+	signal(LanguageSwift, 1, `\blet \w+ = |\bvar \w+ = `),
+	signal(LanguageRuby, 3, `(?m)# @param \{\w+(\[\])?\}|\bdef \w+\([^)]*\)\s*$|\.each do\b`),
+	signal(LanguageRuby, 1, `(?m)^\s*end\s*$|\bnil\b|\belsif\b`),
+	signal(LanguagePHP, 3, `<\?php|\$this->|\bfunction \w+\(\$`),
+	// Kotlin has objects too
+	signal(LanguageScala, 3, `\bdef \w+\([^)]*:\s*(Int|Array\[Int\]|String)`),
+	signal(LanguageScala, 1, `\bArray\[\w+\]|\bcase (Some|None)\b`),
+	signal(LanguageScala, 1, `\bobject Solution\b`),
+	// queries may follow a comment line; WITH only counts as a CTE so a
+	// Python with statement does not
+	signal(LanguageSQL, 4, `(?im)^\s*(select\b|with\s+\w+\s+as\s*\(|delete\b|update\b)[\s\S]*\bfrom\b`),
+}
+
+// Detect guesses the language of a solution from characteristic syntax
+// and returns "" when no language clearly wins
+func Detect(code string) string {
+	scores := make(map[string]int)
+	for _, s := range signals {
+		if s.pattern.MatchString(code) {
+			scores[s.language] += s.weight
+		}
+	}
+
+	best, bestScore, tied := "", 0, false
+	for language, score := range scores {
+		switch {
+		case score > bestScore:
+			best, bestScore, tied = language, score, false
+		case score == bestScore:
+			tied = true
+		}
+	}
+	if bestScore < 3 || tied {
+		return ""
+	}
+	return best
+}
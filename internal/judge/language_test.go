@@ -0,0 +1,313 @@
+package judge
+
+import "testing"
+
+// templates are typical LeetCode solutions, written into each language's
+// starter code
+var templates = []struct {
+	language string
+	code     string
+}{
+	{LanguageCPP, `class Solution {
+public:
+    vector<int> twoSum(vector<int>& nums, int target) {
+        unordered_map<int, int> seen;
+        for (int i = 0; i < nums.size(); i++) {
+            auto it = seen.find(target - nums[i]);
+            if (it != seen.end()) return {it->second, i};
+            seen[nums[i]] = i;
+        }
+        return {};
+    }
+};`},
+	{LanguageC, `/**
+ * Note: The returned array must be malloced, assume caller calls free().
+ */
+int* twoSum(int* nums, int numsSize, int target, int* returnSize) {
+    int* result = malloc(2 * sizeof(int));
+    *returnSize = 2;
+    for (int i = 0; i < numsSize; i++) {
+        for (int j = i + 1; j < numsSize; j++) {
+            if (nums[i] + nums[j] == target) {
+                result[0] = i;
+                result[1] = j;
+                return result;
+            }
+        }
+    }
+    *returnSize = 0;
+    return result;
+}`},
+	{LanguageJava, `class Solution {
+    public int[] twoSum(int[] nums, int target) {
+        Map<Integer, Integer> seen = new HashMap<>();
+        for (int i = 0; i < nums.length; i++) {
+            if (seen.containsKey(target - nums[i])) {
+                return new int[] {seen.get(target - nums[i]), i};
+            }
+            seen.put(nums[i], i);
+        }
+        return new int[0];
+    }
+}`},
+	{LanguageJava, `class Solution {
+    public boolean isPalindrome(String s) {
+        int i = 0, j = s.length() - 1;
+        while (i < j) {
+            if (s.charAt(i++) != s.charAt(j--)) {
+                return false;
+            }
+        }
+        return true;
+    }
+}`},
+	{LanguageCSharp, `public class Solution {
+    public int[] TwoSum(int[] nums, int target) {
+        var seen = new Dictionary<int, int>();
+        for (int i = 0; i < nums.Length; i++) {
+            if (seen.TryGetValue(target - nums[i], out int j)) {
+                return new int[] { j, i };
+            }
+            seen[nums[i]] = i;
+        }
+        return new int[0];
+    }
+}`},
+	{LanguageCSharp, `public class Solution {
+    public bool IsPalindrome(string s) {
+        int i = 0, j = s.Length - 1;
+        while (i < j) {
+            if (s[i++] != s[j--]) {
+                return false;
+            }
+        }
+        return true;
+    }
+}`},
+	{LanguageGo, `func twoSum(nums []int, target int) []int {
+    seen := make(map[int]int)
+    for i, n := range nums {
+        if j, ok := seen[target-n]; ok {
+            return []int{j, i}
+        }
+        seen[n] = i
+    }
+    return nil
+}`},
+	{LanguagePython, `class Solution:
+    def twoSum(self, nums: List[int], target: int) -> List[int]:
+        seen = {}
+        for i, n in enumerate(nums):
+            if target - n in seen:
+                return [seen[target - n], i]
+            seen[n] = i
+        return []`},
+	{LanguagePython, `class Solution(object):
+    def isPalindrome(self, s):
+        """
+        :type s: str
+        :rtype: bool
+        """
+        t = [c.lower() for c in s if c.isalnum()]
+        return t == t[::-1]`},
+	{LanguageJavaScript, `/**
+ * @param {number[]} nums
+ * @param {number} target
+ * @return {number[]}
+ */
+var twoSum = function(nums, target) {
+    const seen = new Map();
+    for (let i = 0; i < nums.length; i++) {
+        if (seen.has(target - nums[i])) {
+            return [seen.get(target - nums[i]), i];
+        }
+        seen.set(nums[i], i);
+    }
+    return [];
+};`},
+	{LanguageTypeScript, `function twoSum(nums: number[], target: number): number[] {
+    const seen = new Map<number, number>();
+    for (let i = 0; i < nums.length; i++) {
+        const j = seen.get(target - nums[i]);
+        if (j !== undefined) {
+            return [j, i];
+        }
+        seen.set(nums[i], i);
+    }
+    return [];
+};`},
+	{LanguageKotlin, `class Solution {
+    fun twoSum(nums: IntArray, target: Int): IntArray {
+        val seen = HashMap<Int, Int>()
+        for ((i, n) in nums.withIndex()) {
+            seen[target - n]?.let { return intArrayOf(it, i) }
+            seen[n] = i
+        }
+        return intArrayOf()
+    }
+}`},
+	{LanguageScala, `object Solution {
+    def twoSum(nums: Array[Int], target: Int): Array[Int] = {
+        val seen = scala.collection.mutable.HashMap[Int, Int]()
+        for (i <- nums.indices) {
+            seen.get(target - nums(i)) match {
+                case Some(j) => return Array(j, i)
+                case None => seen(nums(i)) = i
+            }
+        }
+        Array()
+    }
+}`},
+	{LanguageSwift, `class Solution {
+    func twoSum(_ nums: [Int], _ target: Int) -> [Int] {
+        var seen = [Int: Int]()
+        for (i, n) in nums.enumerated() {
+            if let j = seen[target - n] {
+                return [j, i]
+            }
+            seen[n] = i
+        }
+        return []
+    }
+}`},
+	{LanguageRust, `impl Solution {
+    pub fn two_sum(nums: Vec<i32>, target: i32) -> Vec<i32> {
+        let mut seen = std::collections::HashMap::new();
+        for (i, &n) in nums.iter().enumerate() {
+            if let Some(&j) = seen.get(&(target - n)) {
+                return vec![j as i32, i as i32];
+            }
+            seen.insert(n, i);
+        }
+        vec![]
+    }
+}`},
+	{LanguageRuby, `# @param {Integer[]} nums
+# @param {Integer} target
+# @return {Integer[]}
+def two_sum(nums, target)
+  seen = {}
+  nums.each_with_index do |n, i|
+    return [seen[target - n], i] if seen.key?(target - n)
+    seen[n] = i
+  end
+end`},
+	{LanguagePHP, `class Solution {
+
+    /**
+     * @param Integer[] $nums
+     * @param Integer $target
+     * @return Integer[]
+     */
+    function twoSum($nums, $target) {
+        $seen = [];
+        foreach ($nums as $i => $n) {
+            if (isset($seen[$target - $n])) {
+                return [$seen[$target - $n], $i];
+            }
+            $seen[$n] = $i;
+        }
+        return [];
+    }
+}`},
+	{LanguageSQL, `# Write your MySQL query statement below
+SELECT p.firstName, p.lastName, a.city, a.state
+FROM Person p
+LEFT JOIN Address a ON p.personId = a.personId;`},
+	{LanguageSQL, `select name as Customers from Customers
+where id not in (select customerId from Orders)`},
+	{LanguageSQL, `WITH ranked AS (
+    SELECT id, DENSE_RANK() OVER (ORDER BY salary DESC) AS r FROM Employee
+)
+SELECT id FROM ranked WHERE r = 2;`},
+	{LanguagePython, `class Solution:
+    def firstLine(self, path: str) -> str:
+        with open(path) as f:
+            return f.readline()  # read from the top`},
+}
+
+func TestDetectTemplates(t *testing.T) {
+	for _, tt := range templates {
+		if got := Detect(tt.code); got != tt.language {
+			t.Errorf("Detect(%s template) = %q\n%s", tt.language, got, tt.code)
+		}
+	}
+}
+
+// TestDetectTies covers code the languages of a pair share, which must
+// not be attributed to either
+func TestDetectTies(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+	}{
+		{"Java or C# with a camelCase method", `class Solution {
+    public int climbStairs(int n) {
+        int a = 1, b = 1;
+        for (int i = 0; i < n; i++) {
+            int c = a + b;
+            a = b;
+            b = c;
+        }
+        return a;
+    }
+}`},
+		{"Java or C# with a PascalCase method", `public class Solution {
+    public int ClimbStairs(int n) {
+        int[] ways = new int[n + 2];
+        ways[0] = 1;
+        ways[1] = 1;
+        for (int i = 2; i <= n; i++) {
+            ways[i] = ways[i - 1] + ways[i - 2];
+        }
+        return ways[n];
+    }
+}`},
+		{"Java or C# with equal evidence", `using System;
+class Solution {
+    void Print(string s) {
+        System.out.println(s);
+    }
+}`},
+		{"JavaScript or TypeScript without annotations", `var climbStairs = function(n) {
+    let a = 1, b = 1;
+    for (let i = 0; i < n; i++) {
+        [a, b] = [b, a + b];
+    }
+    return a;
+};`},
+		{"JavaScript or TypeScript arrow function", `const maxDepth = (root) => {
+    if (root === null) return 0;
+    return 1 + Math.max(maxDepth(root.left), maxDepth(root.right));
+};`},
+		{"Kotlin or Scala object", `object Solution {
+    val mod = 1000000007
+    var calls = 0
+}`},
+		{"Kotlin or Scala with equal evidence", `class Solution {
+    fun climbStairs(n: Int): Int = n
+    def climbStairs(n: Int): Int = n
+}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Detect(tt.code); got != "" {
+				t.Errorf("Detect = %q, want no language\n%s", got, tt.code)
+			}
+		})
+	}
+}
+
+func TestNormalizeLanguage(t *testing.T) {
+	for input, want := range map[string]string{
+		" Python3 ": LanguagePython,
+		"C++":       LanguageCPP,
+		"golang":    LanguageGo,
+		"MySQL":     LanguageSQL,
+		"elixir":    "elixir",
+	} {
+		if got := NormalizeLanguage(input); got != want {
+			t.Errorf("NormalizeLanguage(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package judge
+
+import "strings"
+
+// Statuses are the judge verdicts a submission can carry
+const (
+	StatusAccepted            = "accepted"
+	StatusWrongAnswer         = "wrong_answer"
+	StatusTimeLimitExceeded   = "time_limit_exceeded"
+	StatusMemoryLimitExceeded = "memory_limit_exceeded"
+	StatusOutputLimitExceeded = "output_limit_exceeded"
+	StatusRuntimeError        = "runtime_error"
+	StatusCompileError        = "compile_error"
+)
+
+var statusAliases = map[string]string{
+	"ac":  StatusAccepted,
+	"wa":  StatusWrongAnswer,
+	"tle": StatusTimeLimitExceeded,
+	"mle": StatusMemoryLimitExceeded,
+	"ole": StatusOutputLimitExceeded,
+	"re":  StatusRuntimeError,
+	"ce":  StatusCompileError,
+}
+
+// NormalizeStatus maps a verdict such as "Time Limit Exceeded", "TLE" or
+// "time_limit_exceeded" to its status constant. Empty and unknown verdicts
+// report false
+func NormalizeStatus(status string) (string, bool) {
+	status = strings.ToLower(strings.TrimSpace(status))
+	if canonical, ok := statusAliases[status]; ok {
+		return canonical, true
+	}
+	status = strings.NewReplacer(" ", "_", "-", "_").Replace(status)
+	switch status {
+	case StatusAccepted, StatusWrongAnswer, StatusTimeLimitExceeded, StatusMemoryLimitExceeded,
+		StatusOutputLimitExceeded, StatusRuntimeError, StatusCompileError:
+		return status, true
+	}
+	return "", false
+}
@@ -0,0 +1,25 @@
+package judge
+
+import "testing"
+
+func TestNormalizeStatus(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"Accepted", StatusAccepted, true},
+		{" TLE ", StatusTimeLimitExceeded, true},
+		{"Time Limit Exceeded", StatusTimeLimitExceeded, true},
+		{"wrong-answer", StatusWrongAnswer, true},
+		{"", "", false},
+		{"   ", "", false},
+		{"pending", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := NormalizeStatus(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("NormalizeStatus(%q) = %q, %v, want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
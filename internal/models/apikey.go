@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+type API_Key struct {
+	Key_ID       uint
+	Username     string
+	Name         string
+	Prefix       string
+	Scopes       StringArray
+	Created_At   time.Time
+	Last_Used_At *time.Time
+	Revoked_At   *time.Time
+}
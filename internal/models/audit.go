@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+type Auth_Event struct {
+	Event_ID   uint
+	Event      string
+	Username   string
+	IP         string
+	User_Agent string
+	Details    string
+	Created_At time.Time
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+type Invite struct {
+	Invite_ID  uint
+	Prefix     string
+	Note       string
+	Created_By string
+	Max_Uses   int
+	Uses       int
+	Expires_At time.Time
+	Created_At time.Time
+	Revoked_At *time.Time
+	Accounts   StringArray
+}
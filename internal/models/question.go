@@ -24,19 +24,35 @@ func (sa *StringArray) Scan(value interface{}) error {
 }
 
 type Leetcode_Questions struct {
-	Slug        string
-	Title       string
-	Description string
+	Slug            string
+	Title           string
+	Description     string
+	Difficulty      string
+	Platform_ID     *int
+	URL             string
+	Is_Premium      bool
+	Topics          StringArray
+	Hints           StringArray
+	Similar_Slugs   StringArray
+	Acceptance_Rate *float64
 }
 
 type Question_Tags struct {
-	Slug string
-	Tags StringArray
+	Username string
+	Slug     string
+	Tags     StringArray
 }
 
 type Leetcode_submissions struct {
-	Submission_ID uint
-	Question_Slug string
-	Code          string
-	Submitted_At  time.Time
+	Submission_ID      uint
+	Username           string
+	Question_Slug      string
+	Code               string
+	Submitted_At       time.Time
+	Language           string
+	Status             string
+	Runtime_Ms         *int
+	Memory_Mb          *float64
+	Runtime_Percentile *float64
+	Memory_Percentile  *float64
 }
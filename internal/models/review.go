@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+type Review_Schedule struct {
+	Username         string
+	Question_Slug    string
+	Ease_Factor      float64
+	Interval_Days    int
+	Repetitions      int
+	Due_At           time.Time
+	Last_Reviewed_At *time.Time
+}
+
+type Review_Log struct {
+	Review_ID          uint
+	Username           string
+	Question_Slug      string
+	Grade              int
+	Time_Spent_Seconds int
+	Notes              string
+	Reviewed_At        time.Time
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Search_Result is a ranked full-text match, either on a question's title
+// and description or on the code of a submission
+type Search_Result struct {
+	Kind          string
+	Question_Slug string
+	Title         string
+	Submission_ID *uint
+	Submitted_At  *time.Time
+	Rank          float64
+	// Snippet is HTML escaped, with matches wrapped in <mark> tags
+	Snippet string
+}
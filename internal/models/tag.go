@@ -0,0 +1,9 @@
+package models
+
+type Tag struct {
+	Tag_ID    int64
+	Name      string
+	Parent_ID *int64
+	Color     string
+	Aliases   StringArray
+}
@@ -4,4 +4,7 @@ type User struct {
 	Name     string
 	Username string
 	Password string
+	Role     string
+	Email    string
+	Timezone string
 }
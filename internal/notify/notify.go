@@ -0,0 +1,55 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+)
+
+// Message is a notification addressed to a single recipient
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Notifier delivers messages to users, e.g. password reset links
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// LogNotifier writes messages to the server log instead of delivering
+// them. It is meant for local development only
+type LogNotifier struct{}
+
+func (LogNotifier) Send(ctx context.Context, msg Message) error {
+	log.Printf("Notification to %s | Subject: %s | %s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FromEnv builds the notifier selected by NOTIFIER ("smtp" or "log").
+// NOTIFIER has to be set explicitly so a deployment cannot silently fall
+// back to logging reset links
+func FromEnv() (Notifier, error) {
+	switch kind := os.Getenv("NOTIFIER"); kind {
+	case "smtp":
+		n := SMTPNotifier{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     os.Getenv("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("SMTP_FROM"),
+		}
+		if n.Host == "" || n.From == "" {
+			return nil, fmt.Errorf("NOTIFIER=smtp requires SMTP_HOST and SMTP_FROM")
+		}
+		return n, nil
+	case "log":
+		return LogNotifier{}, nil
+	case "":
+		return nil, fmt.Errorf("NOTIFIER not set, expected smtp or log")
+	default:
+		return nil, fmt.Errorf("unknown NOTIFIER %q, expected smtp or log", kind)
+	}
+}
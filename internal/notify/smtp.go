@@ -0,0 +1,107 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPNotifier delivers messages through an SMTP relay. Authentication is
+// skipped when Username is empty, which is what local SMTP stand-ins such
+// as MailHog expect
+type SMTPNotifier struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// Send delivers msg, giving up once ctx is done
+func (n SMTPNotifier) Send(ctx context.Context, msg Message) error {
+	if n.Host == "" || n.From == "" {
+		return fmt.Errorf("smtp notifier requires SMTP_HOST and SMTP_FROM")
+	}
+	if strings.ContainsAny(msg.To, "\r\n") || strings.ContainsAny(msg.Subject, "\r\n") {
+		return fmt.Errorf("invalid message header")
+	}
+	port := n.Port
+	if port == "" {
+		port = "25"
+	}
+
+	body := "From: " + n.From + "\r\n" +
+		"To: " + msg.To + "\r\n" +
+		"Subject: " + msg.Subject + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + msg.Body + "\r\n"
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(n.Host, port))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// the deadline bounds a stalled relay, the cancellation an abandoned send
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	client, err := smtp.NewClient(conn, n.Host)
+	if err != nil {
+		return withContext(ctx, err)
+	}
+	defer client.Close()
+	return withContext(ctx, n.deliver(client, msg.To, body))
+}
+
+// deliver runs the SMTP transaction the way smtp.SendMail does
+func (n SMTPNotifier) deliver(client *smtp.Client, to string, body string) error {
+	if err := client.Hello("localhost"); err != nil {
+		return err
+	}
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: n.Host}); err != nil {
+			return err
+		}
+	}
+	if n.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", n.Username, n.Password, n.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(n.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(body)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// withContext reports the context error instead of the I/O error it caused
+func withContext(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
@@ -0,0 +1,186 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/textproto"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTP accepts a single connection and records the envelope and data
+// of the message it receives
+type fakeSMTP struct {
+	listener net.Listener
+	from     string
+	rcpt     []string
+	data     string
+	done     chan error
+}
+
+func startFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	s := &fakeSMTP{listener: l, done: make(chan error, 1)}
+	go func() { s.done <- s.serve() }()
+	return s
+}
+
+func (s *fakeSMTP) serve() error {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 fake ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return err
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250-fake greets you")
+			tp.PrintfLine("250 8BITMIME")
+		case "MAIL":
+			s.from = arg
+			tp.PrintfLine("250 OK")
+		case "RCPT":
+			s.rcpt = append(s.rcpt, arg)
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return err
+			}
+			s.data = string(data)
+			tp.PrintfLine("250 queued")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return nil
+		default:
+			tp.PrintfLine("502 unknown command")
+		}
+	}
+}
+
+func (s *fakeSMTP) notifier() SMTPNotifier {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return SMTPNotifier{Host: host, Port: port, From: "reviser@example.com"}
+}
+
+func TestSMTPNotifierSend(t *testing.T) {
+	server := startFakeSMTP(t)
+	msg := Message{To: "user@example.com", Subject: "Reset your Reviser password", Body: "token: abc"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.notifier().Send(ctx, msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if err := <-server.done; err != nil {
+		t.Fatalf("server: %v", err)
+	}
+
+	if server.from != "FROM:<reviser@example.com>" && !strings.HasPrefix(server.from, "FROM:<reviser@example.com> ") {
+		t.Errorf("MAIL %s, want FROM:<reviser@example.com>", server.from)
+	}
+	if len(server.rcpt) != 1 || server.rcpt[0] != "TO:<user@example.com>" {
+		t.Errorf("RCPT %v, want [TO:<user@example.com>]", server.rcpt)
+	}
+	header, body, ok := strings.Cut(server.data, "\n\n")
+	if !ok {
+		t.Fatalf("message has no header separator: %q", server.data)
+	}
+	lines := strings.Split(header, "\n")
+	for _, want := range []string{
+		"From: reviser@example.com",
+		"To: user@example.com",
+		"Subject: Reset your Reviser password",
+		"Content-Type: text/plain; charset=UTF-8",
+	} {
+		if !slices.Contains(lines, want) {
+			t.Errorf("header missing %q:\n%s", want, header)
+		}
+	}
+	if body != "token: abc\n" {
+		t.Errorf("body = %q, want %q", body, "token: abc\n")
+	}
+}
+
+func TestSMTPNotifierSendHonoursContext(t *testing.T) {
+	// a relay that accepts connections but never greets
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		}()
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	n := SMTPNotifier{Host: host, Port: port, From: "reviser@example.com"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = n.Send(ctx, Message{To: "user@example.com", Subject: "s", Body: "b"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Send error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Send took %v after the deadline", elapsed)
+	}
+}
+
+func TestSMTPNotifierRejectsHeaderInjection(t *testing.T) {
+	n := SMTPNotifier{Host: "127.0.0.1", From: "reviser@example.com"}
+	err := n.Send(context.Background(), Message{To: "user@example.com\r\nBcc: other@example.com", Subject: "s"})
+	if err == nil {
+		t.Fatal("Send accepted a recipient with a line break")
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	tests := []struct {
+		env     map[string]string
+		wantErr bool
+	}{
+		{map[string]string{}, true},
+		{map[string]string{"NOTIFIER": "log"}, false},
+		{map[string]string{"NOTIFIER": "smtp"}, true},
+		{map[string]string{"NOTIFIER": "smtp", "SMTP_HOST": "mail", "SMTP_FROM": "a@b.c"}, false},
+		{map[string]string{"NOTIFIER": "pigeon"}, true},
+	}
+	for _, tt := range tests {
+		for _, name := range []string{"NOTIFIER", "SMTP_HOST", "SMTP_FROM"} {
+			t.Setenv(name, tt.env[name])
+		}
+		if _, err := FromEnv(); (err != nil) != tt.wantErr {
+			t.Errorf("FromEnv() with %v: error = %v, want error %v", tt.env, err, tt.wantErr)
+		}
+	}
+}
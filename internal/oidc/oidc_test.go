@@ -0,0 +1,325 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	testClientID = "reviser"
+	testCode     = "auth-code"
+	testNonce    = "nonce-123"
+)
+
+// mockIssuer is an in-process OpenID provider serving discovery, a JWKS
+// and a token endpoint that checks the PKCE verifier of the one code it
+// issued
+type mockIssuer struct {
+	server    *httptest.Server
+	key       *rsa.PrivateKey
+	challenge string
+	// idToken builds the ID token returned by the token endpoint
+	idToken func(m *mockIssuer) string
+}
+
+var (
+	issuerKey = mustRSAKey()
+	otherKey  = mustRSAKey()
+)
+
+func mustRSAKey() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+	m := &mockIssuer{key: issuerKey}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.server.URL,
+			"authorization_endpoint": m.server.URL + "/authorize",
+			"token_endpoint":         m.server.URL + "/token",
+			"jwks_uri":               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		b64 := base64.RawURLEncoding.EncodeToString
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kid": "k1", "kty": "RSA", "use": "sig", "alg": "RS256",
+			"n": b64(m.key.N.Bytes()), "e": b64(big.NewInt(int64(m.key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "bad form", http.StatusBadRequest)
+			return
+		}
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if r.PostForm.Get("grant_type") != "authorization_code" || r.PostForm.Get("code") != testCode ||
+			r.PostForm.Get("client_id") != testClientID ||
+			base64.RawURLEncoding.EncodeToString(sum[:]) != m.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "at", "token_type": "Bearer", "id_token": m.idToken(m)})
+	})
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+func (m *mockIssuer) provider() *Provider {
+	return &Provider{
+		Issuer:      m.server.URL,
+		ClientID:    testClientID,
+		RedirectURL: "https://reviser.example/auth/oidc/callback",
+		Scopes:      []string{"openid", "email"},
+		client:      m.server.Client(),
+	}
+}
+
+// claims returns valid ID token claims, changed by edit
+func (m *mockIssuer) claims(edit func(jwt.MapClaims)) jwt.MapClaims {
+	claims := jwt.MapClaims{
+		"iss":            m.server.URL,
+		"aud":            testClientID,
+		"sub":            "subject-1",
+		"exp":            time.Now().Add(time.Minute).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          testNonce,
+		"email":          "alice@example.com",
+		"email_verified": true,
+		"name":           "Alice",
+	}
+	if edit != nil {
+		edit(claims)
+	}
+	return claims
+}
+
+func signRS256(key *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		panic(err)
+	}
+	return signed
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	m := newMockIssuer(t)
+	verifier, challenge, err := NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	if challenge != base64.RawURLEncoding.EncodeToString(sum[:]) {
+		t.Errorf("challenge %q is not the S256 of the verifier", challenge)
+	}
+
+	authURL, err := m.provider().AuthCodeURL(context.Background(), "state-1", testNonce, challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := u.Scheme + "://" + u.Host + u.Path; got != m.server.URL+"/authorize" {
+		t.Errorf("authorization endpoint = %s", got)
+	}
+	q := u.Query()
+	for key, want := range map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"redirect_uri":          "https://reviser.example/auth/oidc/callback",
+		"scope":                 "openid email",
+		"state":                 "state-1",
+		"nonce":                 testNonce,
+		"code_challenge":        challenge,
+		"code_challenge_method": "S256",
+	} {
+		if got := q.Get(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestExchange(t *testing.T) {
+	tests := []struct {
+		name    string
+		idToken func(m *mockIssuer) string
+		// verifier replaces the PKCE verifier sent to the token endpoint
+		verifier string
+		wantErr  string
+	}{
+		{
+			name:    "valid",
+			idToken: func(m *mockIssuer) string { return signRS256(m.key, "k1", m.claims(nil)) },
+		},
+		{
+			name:    "single key without kid",
+			idToken: func(m *mockIssuer) string { return signRS256(m.key, "", m.claims(nil)) },
+		},
+		{
+			name: "audience list containing the client",
+			idToken: func(m *mockIssuer) string {
+				return signRS256(m.key, "k1", m.claims(func(c jwt.MapClaims) { c["aud"] = []string{"other", testClientID} }))
+			},
+		},
+		{
+			name: "nonce mismatch",
+			idToken: func(m *mockIssuer) string {
+				return signRS256(m.key, "k1", m.claims(func(c jwt.MapClaims) { c["nonce"] = "replayed" }))
+			},
+			wantErr: "nonce",
+		},
+		{
+			name: "missing nonce",
+			idToken: func(m *mockIssuer) string {
+				return signRS256(m.key, "k1", m.claims(func(c jwt.MapClaims) { delete(c, "nonce") }))
+			},
+			wantErr: "nonce",
+		},
+		{
+			name: "wrong audience",
+			idToken: func(m *mockIssuer) string {
+				return signRS256(m.key, "k1", m.claims(func(c jwt.MapClaims) { c["aud"] = "another-client" }))
+			},
+			wantErr: "audience",
+		},
+		{
+			name: "wrong issuer",
+			idToken: func(m *mockIssuer) string {
+				return signRS256(m.key, "k1", m.claims(func(c jwt.MapClaims) { c["iss"] = "https://evil.example" }))
+			},
+			wantErr: "issuer",
+		},
+		{
+			name: "expired",
+			idToken: func(m *mockIssuer) string {
+				return signRS256(m.key, "k1", m.claims(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }))
+			},
+			wantErr: "expired",
+		},
+		{
+			name: "missing expiry",
+			idToken: func(m *mockIssuer) string {
+				return signRS256(m.key, "k1", m.claims(func(c jwt.MapClaims) { delete(c, "exp") }))
+			},
+			wantErr: "expired",
+		},
+		{
+			name:    "bad signature",
+			idToken: func(m *mockIssuer) string { return signRS256(otherKey, "k1", m.claims(nil)) },
+			wantErr: "verification error",
+		},
+		{
+			name:    "unknown kid",
+			idToken: func(m *mockIssuer) string { return signRS256(m.key, "k2", m.claims(nil)) },
+			wantErr: "unknown signing key",
+		},
+		{
+			name: "HMAC keyed with the public key",
+			idToken: func(m *mockIssuer) string {
+				token := jwt.NewWithClaims(jwt.SigningMethodHS256, m.claims(nil))
+				token.Header["kid"] = "k1"
+				signed, _ := token.SignedString(m.key.PublicKey.N.Bytes())
+				return signed
+			},
+			wantErr: "signing method",
+		},
+		{
+			name: "missing subject",
+			idToken: func(m *mockIssuer) string {
+				return signRS256(m.key, "k1", m.claims(func(c jwt.MapClaims) { delete(c, "sub") }))
+			},
+			wantErr: "subject",
+		},
+		{
+			name:     "wrong PKCE verifier",
+			idToken:  func(m *mockIssuer) string { return signRS256(m.key, "k1", m.claims(nil)) },
+			verifier: "not-the-verifier",
+			wantErr:  "status 400",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockIssuer(t)
+			m.idToken = tt.idToken
+			verifier, challenge, err := NewPKCE()
+			if err != nil {
+				t.Fatal(err)
+			}
+			m.challenge = challenge
+			if tt.verifier != "" {
+				verifier = tt.verifier
+			}
+
+			claims, err := m.provider().Exchange(context.Background(), testCode, verifier, testNonce)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Exchange error = %v, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Exchange: %v", err)
+			}
+			want := Claims{Subject: "subject-1", Email: "alice@example.com", EmailVerified: true, Name: "Alice"}
+			if *claims != want {
+				t.Errorf("claims = %+v, want %+v", *claims, want)
+			}
+		})
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	m := newMockIssuer(t)
+	// serve the issuer under another URL, so discovery reports an issuer
+	// other than the one the provider is configured with
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.URL.Path = strings.TrimPrefix(r.URL.Path, "/tenant")
+		m.server.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+	p := m.provider()
+	p.Issuer = proxy.URL + "/tenant"
+
+	if _, err := p.AuthCodeURL(context.Background(), "s", "n", "c"); err == nil || !strings.Contains(err.Error(), "issuer mismatch") {
+		t.Errorf("AuthCodeURL error = %v, want an issuer mismatch", err)
+	}
+}
+
+func TestRandomString(t *testing.T) {
+	a, err := RandomString(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := RandomString(32)
+	if len(a) != 43 || a == b {
+		t.Errorf("RandomString(32) = %q, %q", a, b)
+	}
+}
@@ -0,0 +1,53 @@
+package password
+
+import (
+	_ "embed"
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// MinLength and MaxLength bound password length. bcrypt ignores
+// everything after 72 bytes, so longer passwords are rejected
+const (
+	MinLength = 10
+	MaxLength = 72
+)
+
+//go:embed common.txt
+var commonList string
+
+var common = func() map[string]bool {
+	set := make(map[string]bool)
+	for _, line := range strings.Split(commonList, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			set[strings.ToLower(line)] = true
+		}
+	}
+	return set
+}()
+
+var (
+	ErrTooShort    = errors.New("password must be at least 10 characters")
+	ErrTooLong     = errors.New("password must be at most 72 bytes")
+	ErrCommon      = errors.New("password is too common")
+	ErrMatchesUser = errors.New("password must not contain the username")
+)
+
+// Validate checks password against the password policy for username
+func Validate(password string, username string) error {
+	if utf8.RuneCountInString(password) < MinLength {
+		return ErrTooShort
+	}
+	if len(password) > MaxLength {
+		return ErrTooLong
+	}
+	lower := strings.ToLower(password)
+	if common[lower] {
+		return ErrCommon
+	}
+	if username != "" && strings.Contains(lower, strings.ToLower(username)) {
+		return ErrMatchesUser
+	}
+	return nil
+}
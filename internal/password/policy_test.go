@@ -0,0 +1,44 @@
+package password
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		username string
+		want     error
+	}{
+		{"valid", "correct horse battery", "alice", nil},
+		{"too short", "abc123!", "alice", ErrTooShort},
+		{"short in runes, long in bytes", "ééééééééé", "alice", ErrTooShort},
+		{"multibyte at min length", "éééééééééé", "alice", nil},
+		{"at max length", strings.Repeat("x", MaxLength-1) + "y", "alice", nil},
+		{"too long", strings.Repeat("x", MaxLength) + "y", "alice", ErrTooLong},
+		{"common", "1234567890", "alice", ErrCommon},
+		{"common ignores case", "QWERTYUIOP", "alice", ErrCommon},
+		{"contains username", "my-Alice-password", "alice", ErrMatchesUser},
+		{"no username", "my-alice-password", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Validate(tt.password, tt.username); got != tt.want {
+				t.Errorf("Validate(%q, %q) = %v, want %v", tt.password, tt.username, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommonListLoaded(t *testing.T) {
+	if len(common) == 0 {
+		t.Fatal("common password list is empty")
+	}
+	for password := range common {
+		if password != strings.ToLower(strings.TrimSpace(password)) {
+			t.Errorf("common entry %q is not normalized", password)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package ratelimit
+
+import (
+	"context"
+	"reviser/internal/inits"
+	"time"
+)
+
+// Hit counts a request against key in rate_limits and returns how long the
+// caller has to wait once more than limit requests were made in the
+// current window
+func Hit(ctx context.Context, key string, limit int, window time.Duration) (time.Duration, error) {
+	var hits int
+	var windowStart time.Time
+	err := inits.DB.QueryRowContext(ctx,
+		`INSERT INTO rate_limits (key, hits, window_start)
+			VALUES ($1, 1, now())
+			ON CONFLICT (key) DO UPDATE SET
+				hits = CASE
+					WHEN rate_limits.window_start < now() - make_interval(secs => $2) THEN 1
+					ELSE rate_limits.hits + 1
+				END,
+				window_start = CASE
+					WHEN rate_limits.window_start < now() - make_interval(secs => $2) THEN now()
+					ELSE rate_limits.window_start
+				END
+			RETURNING hits, window_start`,
+		key, window.Seconds()).Scan(&hits, &windowStart)
+	if err != nil || hits <= limit {
+		return 0, err
+	}
+	return time.Until(windowStart.Add(window)), nil
+}
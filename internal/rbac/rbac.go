@@ -0,0 +1,44 @@
+package rbac
+
+// Roles a user can hold, from most to least privileged
+const (
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// Permissions checked by middlewares.RequirePermission
+const (
+	PermContentRead = "content:read"
+	PermTagsEdit    = "tags:edit"
+	PermCronIngest  = "cron:ingest"
+	PermAdmin       = "admin"
+)
+
+var rolePermissions = map[string]map[string]bool{
+	RoleAdmin: {
+		PermContentRead: true,
+		PermTagsEdit:    true,
+		PermCronIngest:  true,
+		PermAdmin:       true,
+	},
+	RoleEditor: {
+		PermContentRead: true,
+		PermTagsEdit:    true,
+		PermCronIngest:  true,
+	},
+	RoleViewer: {
+		PermContentRead: true,
+	},
+}
+
+// ValidRole reports whether role is a known role
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// Can reports whether role has been granted permission
+func Can(role string, permission string) bool {
+	return rolePermissions[role][permission]
+}
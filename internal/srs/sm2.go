@@ -0,0 +1,63 @@
+package srs
+
+import (
+	"math"
+	"time"
+)
+
+// Grades follow the SM-2 scale where 0 is a complete blackout
+// and 5 is a perfect recall
+const (
+	MinGrade = 0
+	MaxGrade = 5
+
+	DefaultEaseFactor = 2.5
+	minEaseFactor     = 1.3
+)
+
+// Card is the scheduling state of a single question for a single user
+type Card struct {
+	EaseFactor   float64
+	IntervalDays int
+	Repetitions  int
+}
+
+// NewCard returns the state of a question that has never been reviewed
+func NewCard() Card {
+	return Card{EaseFactor: DefaultEaseFactor}
+}
+
+// ValidGrade reports whether grade is within the SM-2 scale
+func ValidGrade(grade int) bool {
+	return grade >= MinGrade && grade <= MaxGrade
+}
+
+// Review applies a grade to the card using the SM-2 algorithm and
+// returns the updated card along with the next due time
+func Review(card Card, grade int, reviewedAt time.Time) (Card, time.Time) {
+	if grade < 3 {
+		// failed recall restarts the repetition sequence and, as in the
+		// original SM-2, leaves the ease factor alone
+		card.Repetitions = 0
+		card.IntervalDays = 1
+		return card, reviewedAt.AddDate(0, 0, card.IntervalDays)
+	}
+
+	switch card.Repetitions {
+	case 0:
+		card.IntervalDays = 1
+	case 1:
+		card.IntervalDays = 6
+	default:
+		card.IntervalDays = int(math.Round(float64(card.IntervalDays) * card.EaseFactor))
+	}
+	card.Repetitions++
+
+	q := float64(MaxGrade - grade)
+	card.EaseFactor += 0.1 - q*(0.08+q*0.02)
+	if card.EaseFactor < minEaseFactor {
+		card.EaseFactor = minEaseFactor
+	}
+
+	return card, reviewedAt.AddDate(0, 0, card.IntervalDays)
+}
@@ -0,0 +1,46 @@
+package srs
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestReview(t *testing.T) {
+	reviewedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		card     Card
+		grade    int
+		want     Card
+		wantDays int
+	}{
+		{"first success", NewCard(), 4, Card{EaseFactor: 2.5, IntervalDays: 1, Repetitions: 1}, 1},
+		{"second success", Card{EaseFactor: 2.5, IntervalDays: 1, Repetitions: 1}, 4, Card{EaseFactor: 2.5, IntervalDays: 6, Repetitions: 2}, 6},
+		{"third success uses ease factor", Card{EaseFactor: 2.5, IntervalDays: 6, Repetitions: 2}, 5, Card{EaseFactor: 2.6, IntervalDays: 15, Repetitions: 3}, 15},
+		{"hard success lowers ease factor", Card{EaseFactor: 2.5, IntervalDays: 6, Repetitions: 2}, 3, Card{EaseFactor: 2.36, IntervalDays: 15, Repetitions: 3}, 15},
+		{"failure keeps ease factor", Card{EaseFactor: 2.2, IntervalDays: 15, Repetitions: 3}, 0, Card{EaseFactor: 2.2, IntervalDays: 1, Repetitions: 0}, 1},
+		{"failure at grade 2", Card{EaseFactor: 1.3, IntervalDays: 40, Repetitions: 5}, 2, Card{EaseFactor: 1.3, IntervalDays: 1, Repetitions: 0}, 1},
+		{"ease factor floor", Card{EaseFactor: 1.35, IntervalDays: 6, Repetitions: 2}, 3, Card{EaseFactor: minEaseFactor, IntervalDays: 8, Repetitions: 3}, 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, due := Review(tt.card, tt.grade, reviewedAt)
+			if got.IntervalDays != tt.want.IntervalDays || got.Repetitions != tt.want.Repetitions ||
+				math.Abs(got.EaseFactor-tt.want.EaseFactor) > 1e-9 {
+				t.Errorf("Review(%+v, %d) = %+v, want %+v", tt.card, tt.grade, got, tt.want)
+			}
+			if want := reviewedAt.AddDate(0, 0, tt.wantDays); !due.Equal(want) {
+				t.Errorf("due = %v, want %v", due, want)
+			}
+		})
+	}
+}
+
+func TestValidGrade(t *testing.T) {
+	for grade := -1; grade <= 6; grade++ {
+		if got, want := ValidGrade(grade), grade >= 0 && grade <= 5; got != want {
+			t.Errorf("ValidGrade(%d) = %v, want %v", grade, got, want)
+		}
+	}
+}
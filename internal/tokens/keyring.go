@@ -0,0 +1,283 @@
+package tokens
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// legacyKeyID identifies the key built from JWT_SECRET when no keyring file
+// is configured
+const legacyKeyID = "default"
+
+// Key is one signing key of the keyring
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+	// signKey is nil for keys that may only verify
+	signKey   interface{}
+	verifyKey interface{}
+	// ExpiresAt ends the grace window of a retired key. Zero means no expiry
+	ExpiresAt time.Time
+}
+
+func (k *Key) usable(now time.Time) bool {
+	return k.ExpiresAt.IsZero() || now.Before(k.ExpiresAt)
+}
+
+// Keyring holds every key tokens may be signed with. New tokens are signed
+// with the signing key, while retired keys keep verifying until they expire
+type Keyring struct {
+	signing *Key
+	keys    map[string]*Key
+}
+
+// keyringFile is the format of the file named by JWT_KEYRING_FILE
+//
+//	{
+//	  "signing_kid": "2026-10",
+//	  "keys": [
+//	    {"kid": "2026-10", "alg": "EdDSA", "private_key_file": "/etc/reviser/ed25519.pem"},
+//	    {"kid": "2026-09", "alg": "HS256", "secret_env": "JWT_SECRET", "expires_at": "2026-10-20T00:00:00Z"}
+//	  ]
+//	}
+type keyringFile struct {
+	SigningKID string `json:"signing_kid"`
+	Keys       []struct {
+		KID            string    `json:"kid"`
+		Alg            string    `json:"alg"`
+		SecretEnv      string    `json:"secret_env"`
+		PrivateKeyFile string    `json:"private_key_file"`
+		PublicKeyFile  string    `json:"public_key_file"`
+		ExpiresAt      time.Time `json:"expires_at"`
+	} `json:"keys"`
+}
+
+var (
+	keyringMu sync.RWMutex
+	keyring   *Keyring
+)
+
+// LoadKeyring loads the keyring from JWT_KEYRING_FILE, or builds a single
+// HS256 key from JWT_SECRET when no file is configured. It is called again
+// to rotate keys without a restart, and keeps the current keyring when the
+// new one does not load
+func LoadKeyring() error {
+	var ring *Keyring
+	var err error
+	if path := os.Getenv("JWT_KEYRING_FILE"); path != "" {
+		ring, err = readKeyring(path)
+	} else {
+		ring, err = legacyKeyring()
+	}
+	if err != nil {
+		return err
+	}
+
+	keyringMu.Lock()
+	keyring = ring
+	keyringMu.Unlock()
+	return nil
+}
+
+func currentKeyring() (*Keyring, error) {
+	keyringMu.RLock()
+	defer keyringMu.RUnlock()
+	if keyring == nil {
+		return nil, fmt.Errorf("keyring not loaded")
+	}
+	return keyring, nil
+}
+
+func legacyKeyring() (*Keyring, error) {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		return nil, fmt.Errorf("JWT_SECRET not set")
+	}
+	key := &Key{ID: legacyKeyID, Method: jwt.SigningMethodHS256, signKey: []byte(secret), verifyKey: []byte(secret)}
+	return &Keyring{signing: key, keys: map[string]*Key{key.ID: key}}, nil
+}
+
+func readKeyring(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file keyringFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid keyring file: %w", err)
+	}
+
+	ring := &Keyring{keys: make(map[string]*Key)}
+	for _, entry := range file.Keys {
+		if entry.KID == "" {
+			return nil, fmt.Errorf("keyring entry without kid")
+		}
+		if _, exists := ring.keys[entry.KID]; exists {
+			return nil, fmt.Errorf("duplicate kid %q", entry.KID)
+		}
+		key := &Key{ID: entry.KID, ExpiresAt: entry.ExpiresAt}
+
+		switch entry.Alg {
+		case "HS256":
+			secret := os.Getenv(entry.SecretEnv)
+			if secret == "" {
+				return nil, fmt.Errorf("key %q: secret_env %q is empty", entry.KID, entry.SecretEnv)
+			}
+			key.Method = jwt.SigningMethodHS256
+			key.signKey = []byte(secret)
+			key.verifyKey = []byte(secret)
+		case "RS256":
+			key.Method = jwt.SigningMethodRS256
+			if entry.PrivateKeyFile != "" {
+				pem, err := os.ReadFile(entry.PrivateKeyFile)
+				if err != nil {
+					return nil, fmt.Errorf("key %q: %w", entry.KID, err)
+				}
+				private, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+				if err != nil {
+					return nil, fmt.Errorf("key %q: %w", entry.KID, err)
+				}
+				key.signKey = private
+				key.verifyKey = &private.PublicKey
+			} else {
+				pem, err := os.ReadFile(entry.PublicKeyFile)
+				if err != nil {
+					return nil, fmt.Errorf("key %q: %w", entry.KID, err)
+				}
+				public, err := jwt.ParseRSAPublicKeyFromPEM(pem)
+				if err != nil {
+					return nil, fmt.Errorf("key %q: %w", entry.KID, err)
+				}
+				key.verifyKey = public
+			}
+		case "EdDSA":
+			key.Method = jwt.SigningMethodEdDSA
+			if entry.PrivateKeyFile != "" {
+				pem, err := os.ReadFile(entry.PrivateKeyFile)
+				if err != nil {
+					return nil, fmt.Errorf("key %q: %w", entry.KID, err)
+				}
+				private, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+				if err != nil {
+					return nil, fmt.Errorf("key %q: %w", entry.KID, err)
+				}
+				key.signKey = private
+				key.verifyKey = private.(ed25519.PrivateKey).Public()
+			} else {
+				pem, err := os.ReadFile(entry.PublicKeyFile)
+				if err != nil {
+					return nil, fmt.Errorf("key %q: %w", entry.KID, err)
+				}
+				public, err := jwt.ParseEdPublicKeyFromPEM(pem)
+				if err != nil {
+					return nil, fmt.Errorf("key %q: %w", entry.KID, err)
+				}
+				key.verifyKey = public
+			}
+		default:
+			return nil, fmt.Errorf("key %q: unsupported alg %q", entry.KID, entry.Alg)
+		}
+		ring.keys[key.ID] = key
+	}
+
+	signing, ok := ring.keys[file.SigningKID]
+	if !ok {
+		return nil, fmt.Errorf("signing_kid %q not found in keyring", file.SigningKID)
+	}
+	if signing.signKey == nil {
+		return nil, fmt.Errorf("signing key %q has no private key", signing.ID)
+	}
+	if !signing.usable(time.Now()) {
+		return nil, fmt.Errorf("signing key %q has expired", signing.ID)
+	}
+	ring.signing = signing
+	return ring, nil
+}
+
+// sign signs claims with the signing key and sets the kid header
+func sign(claims jwt.MapClaims) (string, error) {
+	ring, err := currentKeyring()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(ring.signing.Method, claims)
+	token.Header["kid"] = ring.signing.ID
+	return token.SignedString(ring.signing.signKey)
+}
+
+// verificationKey is the jwt.Keyfunc used to parse tokens. The algorithm
+// must match the one the key was configured with, which rules out
+// algorithm confusion between HMAC secrets and public keys
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	ring, err := currentKeyring()
+	if err != nil {
+		return nil, err
+	}
+	// tokens issued before key ids were introduced carry no kid and were
+	// signed with what is still the signing key
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		kid = ring.signing.ID
+	}
+	key, ok := ring.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	if !key.usable(time.Now()) {
+		return nil, fmt.Errorf("key %q has expired", kid)
+	}
+	return key.verifyKey, nil
+}
+
+// JWKS returns the public keys of the keyring as a JSON Web Key Set.
+// HMAC secrets are never published
+func JWKS() (map[string]interface{}, error) {
+	ring, err := currentKeyring()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	keys := []map[string]interface{}{}
+	for _, key := range ring.keys {
+		if !key.usable(now) {
+			continue
+		}
+		jwk, ok := publicJWK(key)
+		if ok {
+			keys = append(keys, jwk)
+		}
+	}
+	return map[string]interface{}{"keys": keys}, nil
+}
+
+func publicJWK(key *Key) (map[string]interface{}, bool) {
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwk := map[string]interface{}{"kid": key.ID, "alg": key.Method.Alg(), "use": "sig"}
+
+	switch public := key.verifyKey.(type) {
+	case *rsa.PublicKey:
+		jwk["kty"] = "RSA"
+		jwk["n"] = b64(public.N.Bytes())
+		jwk["e"] = b64(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk["kty"] = "OKP"
+		jwk["crv"] = "Ed25519"
+		jwk["x"] = b64(public)
+	default:
+		return nil, false
+	}
+	return jwk, true
+}
@@ -0,0 +1,307 @@
+package tokens
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const testSecret = "test-secret-with-enough-entropy"
+
+func useLegacyKeyring(t *testing.T) {
+	t.Helper()
+	t.Setenv("JWT_KEYRING_FILE", "")
+	t.Setenv("JWT_SECRET", testSecret)
+	if err := LoadKeyring(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeEdKey writes a fresh Ed25519 private key as PKCS#8 PEM
+func writeEdKey(t *testing.T, dir string) (string, ed25519.PrivateKey) {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "ed25519.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path, private
+}
+
+// useKeyringFile writes file as JWT_KEYRING_FILE and loads it
+func useKeyringFile(t *testing.T, dir string, file map[string]interface{}) error {
+	t.Helper()
+	data, err := json.Marshal(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "keyring.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("JWT_KEYRING_FILE", path)
+	return LoadKeyring()
+}
+
+func TestAccessTokenRoundTrip(t *testing.T) {
+	useLegacyKeyring(t)
+
+	signed, err := SignAccessToken("alice", "admin", "session-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := ParseAccessToken(signed)
+	if err != nil {
+		t.Fatalf("ParseAccessToken: %v", err)
+	}
+	if claims["username"] != "alice" || claims["role"] != "admin" || claims["sid"] != "session-1" {
+		t.Errorf("claims = %v", claims)
+	}
+	if _, err := ParseMFAPendingToken(signed); err == nil {
+		t.Error("an access token was accepted as an MFA pending token")
+	}
+}
+
+func TestMFAPendingTokenRoundTrip(t *testing.T) {
+	useLegacyKeyring(t)
+
+	signed, err := SignMFAPendingToken("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	username, err := ParseMFAPendingToken(signed)
+	if err != nil || username != "alice" {
+		t.Errorf("ParseMFAPendingToken = %q, %v", username, err)
+	}
+	if _, err := ParseAccessToken(signed); err == nil {
+		t.Error("an MFA pending token was accepted as an access token")
+	}
+}
+
+func TestExpiredAndTamperedTokens(t *testing.T) {
+	useLegacyKeyring(t)
+
+	jwt.TimeFunc = func() time.Time { return time.Now().Add(-time.Hour) }
+	expired, err := SignAccessToken("alice", "viewer", "s")
+	jwt.TimeFunc = time.Now
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseAccessToken(expired); err == nil {
+		t.Error("an expired token was accepted")
+	}
+
+	signed, err := SignAccessToken("alice", "viewer", "s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(signed, ".")
+	other, err := SignAccessToken("mallory", "admin", "s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// alice's signature on mallory's claims
+	parts[1] = strings.Split(other, ".")[1]
+	if _, err := ParseAccessToken(strings.Join(parts, ".")); err == nil {
+		t.Error("a tampered token was accepted")
+	}
+}
+
+func TestMissingKidUsesSigningKey(t *testing.T) {
+	dir := t.TempDir()
+	keyPath, private := writeEdKey(t, dir)
+	t.Setenv("OLD_SECRET", testSecret)
+	err := useKeyringFile(t, dir, map[string]interface{}{
+		"signing_kid": "new",
+		"keys": []map[string]interface{}{
+			{"kid": "new", "alg": "EdDSA", "private_key_file": keyPath},
+			{"kid": "default", "alg": "HS256", "secret_env": "OLD_SECRET"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := jwt.MapClaims{"username": "alice", "typ": TypeAccess, "exp": time.Now().Add(time.Minute).Unix()}
+	withoutKid, err := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims).SignedString(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseAccessToken(withoutKid); err != nil {
+		t.Errorf("token without kid signed by the signing key rejected: %v", err)
+	}
+
+	// without a kid the retired "default" key is no longer picked
+	legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseAccessToken(legacy); err == nil {
+		t.Error("token without kid verified with a key other than the signing key")
+	}
+}
+
+func TestVerificationKeyRejectsUnknownKidAndAlgorithmConfusion(t *testing.T) {
+	dir := t.TempDir()
+	keyPath, private := writeEdKey(t, dir)
+	if err := useKeyringFile(t, dir, map[string]interface{}{
+		"signing_kid": "ed",
+		"keys":        []map[string]interface{}{{"kid": "ed", "alg": "EdDSA", "private_key_file": keyPath}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	claims := jwt.MapClaims{"username": "alice", "typ": TypeAccess, "exp": time.Now().Add(time.Minute).Unix()}
+
+	unknown := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	unknown.Header["kid"] = "other"
+	signed, _ := unknown.SignedString(private)
+	if _, err := ParseAccessToken(signed); err == nil {
+		t.Error("token with an unknown kid accepted")
+	}
+
+	// an HMAC token keyed with the public key must not verify
+	confused := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	confused.Header["kid"] = "ed"
+	signed, _ = confused.SignedString([]byte(private.Public().(ed25519.PublicKey)))
+	if _, err := ParseAccessToken(signed); err == nil {
+		t.Error("HS256 token accepted for an EdDSA key")
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("OLD_SECRET", testSecret)
+	old := map[string]interface{}{"kid": "old", "alg": "HS256", "secret_env": "OLD_SECRET"}
+	if err := useKeyringFile(t, dir, map[string]interface{}{
+		"signing_kid": "old",
+		"keys":        []map[string]interface{}{old},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	oldToken, err := SignAccessToken("alice", "viewer", "s")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// rotate to a new signing key, keeping the old one for a grace window
+	keyPath, _ := writeEdKey(t, dir)
+	old["expires_at"] = time.Now().Add(time.Hour)
+	if err := useKeyringFile(t, dir, map[string]interface{}{
+		"signing_kid": "new",
+		"keys":        []map[string]interface{}{{"kid": "new", "alg": "EdDSA", "private_key_file": keyPath}, old},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseAccessToken(oldToken); err != nil {
+		t.Errorf("token of the retired key rejected within its grace window: %v", err)
+	}
+	newToken, err := SignAccessToken("alice", "viewer", "s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _, _ := new(jwt.Parser).ParseUnverified(newToken, jwt.MapClaims{})
+	if token.Header["kid"] != "new" || token.Method.Alg() != "EdDSA" {
+		t.Errorf("new token header = %v", token.Header)
+	}
+
+	set, err := JWKS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := set["keys"].([]map[string]interface{})
+	if len(keys) != 1 || keys[0]["kid"] != "new" || keys[0]["kty"] != "OKP" {
+		t.Errorf("JWKS = %v, want only the public Ed25519 key", keys)
+	}
+
+	// once the grace window ends the retired key stops verifying
+	old["expires_at"] = time.Now().Add(-time.Minute)
+	if err := useKeyringFile(t, dir, map[string]interface{}{
+		"signing_kid": "new",
+		"keys":        []map[string]interface{}{{"kid": "new", "alg": "EdDSA", "private_key_file": keyPath}, old},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseAccessToken(oldToken); err == nil {
+		t.Error("token of an expired key accepted")
+	}
+	if _, err := ParseAccessToken(newToken); err != nil {
+		t.Errorf("token of the signing key rejected: %v", err)
+	}
+}
+
+func TestLoadKeyringKeepsCurrentKeysOnError(t *testing.T) {
+	useLegacyKeyring(t)
+	signed, err := SignAccessToken("alice", "viewer", "s")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("JWT_KEYRING_FILE", filepath.Join(t.TempDir(), "missing.json"))
+	if err := LoadKeyring(); err == nil {
+		t.Fatal("LoadKeyring accepted a missing file")
+	}
+	if _, err := ParseAccessToken(signed); err != nil {
+		t.Errorf("a failed reload replaced the keyring: %v", err)
+	}
+}
+
+func TestReadKeyringErrors(t *testing.T) {
+	t.Setenv("OLD_SECRET", testSecret)
+	hs := map[string]interface{}{"kid": "a", "alg": "HS256", "secret_env": "OLD_SECRET"}
+	tests := []struct {
+		name string
+		file map[string]interface{}
+	}{
+		{"duplicate kid", map[string]interface{}{"signing_kid": "a", "keys": []interface{}{hs, hs}}},
+		{"missing signing key", map[string]interface{}{"signing_kid": "b", "keys": []interface{}{hs}}},
+		{"unsupported alg", map[string]interface{}{"signing_kid": "a", "keys": []interface{}{
+			map[string]interface{}{"kid": "a", "alg": "none"}}}},
+		{"empty secret", map[string]interface{}{"signing_kid": "a", "keys": []interface{}{
+			map[string]interface{}{"kid": "a", "alg": "HS256", "secret_env": "UNSET_SECRET"}}}},
+		{"expired signing key", map[string]interface{}{"signing_kid": "a", "keys": []interface{}{
+			map[string]interface{}{"kid": "a", "alg": "HS256", "secret_env": "OLD_SECRET", "expires_at": time.Now().Add(-time.Hour)}}}},
+		{"entry without kid", map[string]interface{}{"signing_kid": "", "keys": []interface{}{
+			map[string]interface{}{"alg": "HS256", "secret_env": "OLD_SECRET"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, _ := json.Marshal(tt.file)
+			path := filepath.Join(t.TempDir(), "keyring.json")
+			if err := os.WriteFile(path, data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := readKeyring(path); err == nil {
+				t.Error("readKeyring succeeded")
+			}
+		})
+	}
+}
+
+func TestOpaqueToken(t *testing.T) {
+	token, hash, err := NewOpaqueToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(token) != 64 || hash != HashOpaqueToken(token) || hash == token {
+		t.Errorf("NewOpaqueToken = %q, %q", token, hash)
+	}
+	if other, _, _ := NewOpaqueToken(); other == token {
+		t.Error("NewOpaqueToken returned the same token twice")
+	}
+}
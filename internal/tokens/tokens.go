@@ -0,0 +1,112 @@
+package tokens
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	AccessTokenTTL     = 15 * time.Minute
+	RefreshTokenTTL    = 30 * 24 * time.Hour
+	MFAPendingTokenTTL = 5 * time.Minute
+)
+
+// Token types carried in the "typ" claim, so a token issued for one
+// purpose is never accepted for another
+const (
+	TypeAccess     = "access"
+	TypeMFAPending = "mfa_pending"
+)
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// NewSessionID returns a random identifier for a refresh token family
+func NewSessionID() (string, error) {
+	return randomHex(16)
+}
+
+// NewOpaqueToken returns a random single-purpose token, such as a refresh
+// or password reset token, and the hash stored for it
+func NewOpaqueToken() (token string, hash string, err error) {
+	token, err = randomHex(32)
+	if err != nil {
+		return "", "", err
+	}
+	return token, HashOpaqueToken(token), nil
+}
+
+// HashOpaqueToken returns the hex encoded SHA-256 of an opaque token
+func HashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// SignAccessToken issues a short-lived access token bound to a session
+func SignAccessToken(username string, role string, sessionID string) (string, error) {
+	return sign(jwt.MapClaims{
+		"username": username,
+		"role":     role,
+		"sid":      sessionID,
+		"typ":      TypeAccess,
+		"exp":      jwt.TimeFunc().Add(AccessTokenTTL).Unix(),
+	})
+}
+
+// SignMFAPendingToken issues the token Login returns when the password
+// was correct but a second factor is still required
+func SignMFAPendingToken(username string) (string, error) {
+	return sign(jwt.MapClaims{
+		"username": username,
+		"typ":      TypeMFAPending,
+		"exp":      jwt.TimeFunc().Add(MFAPendingTokenTTL).Unix(),
+	})
+}
+
+// ParseAccessToken verifies the signature, expiry and type of an access
+// token and returns its claims
+func ParseAccessToken(tokenString string) (jwt.MapClaims, error) {
+	return parse(tokenString, TypeAccess)
+}
+
+// ParseMFAPendingToken verifies an MFA pending token and returns the
+// username it was issued for
+func ParseMFAPendingToken(tokenString string) (string, error) {
+	claims, err := parse(tokenString, TypeMFAPending)
+	if err != nil {
+		return "", err
+	}
+	username, ok := claims["username"].(string)
+	if !ok {
+		return "", fmt.Errorf("invalid token payload")
+	}
+	return username, nil
+}
+
+func parse(tokenString string, typ string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, verificationKey)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, fmt.Errorf("invalid token claims")
+	}
+	if claims["typ"] != typ {
+		return nil, fmt.Errorf("unexpected token type: %v", claims["typ"])
+	}
+	return claims, nil
+}
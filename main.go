@@ -2,7 +2,9 @@ package main
 
 import (
 	"reviser/controllers"
+	"reviser/internal/apikeys"
 	"reviser/internal/inits"
+	"reviser/internal/rbac"
 	"reviser/middlewares"
 
 	"github.com/gin-gonic/gin"
@@ -12,6 +14,10 @@ func init() {
 
 	inits.LoadEnv()
 	inits.DBInit()
+	inits.RunMigrations()
+	inits.NotifierInit()
+	inits.KeyringInit()
+	inits.OIDCInit()
 }
 
 // main function is the entry point of the application
@@ -27,35 +33,86 @@ func main() {
 	// Health Check route
 	r.GET("/health", controllers.HealthCheck)
 
+	// Public keys for verifying tokens issued by this server
+	r.GET("/.well-known/jwks.json", controllers.JWKS)
+
 	// Authentication routes
 	{
 		authGroups := r.Group("/auth")
-		authGroups.GET("/validate", middlewares.RequireAuth, controllers.Validate)
 		authGroups.POST("/signup", controllers.Signup)
 		authGroups.POST("/login", controllers.Login)
-		authGroups.POST("/logout", middlewares.RequireAuth, controllers.Logout)
+		authGroups.POST("/refresh", controllers.Refresh)
+		authGroups.POST("/password/forgot", controllers.ForgotPassword)
+		authGroups.POST("/password/reset", controllers.ResetPassword)
+		authGroups.POST("/mfa/verify", controllers.VerifyMFA)
+		authGroups.GET("/oidc/start", controllers.OIDCStart)
+		authGroups.GET("/oidc/callback", controllers.OIDCCallback)
 
+		// routes for an authenticated session, CSRF checked when the cookie is used
+		sessionRoutes := authGroups.Group("", middlewares.RequireAuth, middlewares.RequireCSRF)
+		sessionRoutes.GET("/validate", controllers.Validate)
+		sessionRoutes.GET("/csrf", controllers.CSRFToken)
+		sessionRoutes.GET("/me", controllers.FetchProfile)
+		sessionRoutes.PATCH("/me", controllers.UpdateProfile)
+		sessionRoutes.DELETE("/me", controllers.DeleteAccount)
+		sessionRoutes.POST("/logout", controllers.Logout)
+		sessionRoutes.POST("/logout/all", controllers.LogoutAll)
+		sessionRoutes.POST("/password/change", controllers.ChangePassword)
+		sessionRoutes.POST("/mfa/enroll", controllers.EnrollMFA)
+		sessionRoutes.POST("/mfa/confirm", controllers.ConfirmMFA)
+		sessionRoutes.POST("/mfa/disable", controllers.DisableMFA)
+		sessionRoutes.POST("/oidc/link", controllers.OIDCLink)
+		sessionRoutes.POST("/apikeys", controllers.CreateAPIKey)
+		sessionRoutes.GET("/apikeys", controllers.FetchAPIKeys)
+		sessionRoutes.DELETE("/apikeys/:id", controllers.RevokeAPIKey)
 	}
 	// Content routes
 	{
 		contentRoutes := r.Group("/api/content")
-		contentRoutes.Use(middlewares.RequireAuth)
+		contentRoutes.Use(middlewares.RequireAuth, middlewares.RequireCSRF, middlewares.RequirePermission(rbac.PermContentRead))
 		contentRoutes.GET("/questions/count", controllers.FetchQuestionsCount)
 		contentRoutes.GET("/questions/all", controllers.FetchAllQuestions)
+		contentRoutes.GET("/questions/tagged", controllers.FetchQuestionsByTags)
 		contentRoutes.GET("/submissions/:slug", controllers.FetchSubmissionsBySlug)
+		contentRoutes.GET("/submissions/:slug/diff", controllers.FetchSubmissionDiff)
 		contentRoutes.GET("/submissions", controllers.FetchSubmissionsForDay)
 		contentRoutes.GET("/pages", controllers.FetchSubmissionsRange)
+		contentRoutes.GET("/range", controllers.FetchSubmissionsBetween)
 		contentRoutes.GET("/tags", controllers.FetchTagsBySlug)
-		contentRoutes.POST("/tags/editor/upsert", controllers.UpsertTags)
-		contentRoutes.DELETE("/tags/editor", controllers.DeleteTags)
+		contentRoutes.GET("/tags/all", controllers.FetchTagCounts)
+		contentRoutes.POST("/tags/editor/upsert", middlewares.RequirePermission(rbac.PermTagsEdit), controllers.UpsertTags)
+		contentRoutes.DELETE("/tags/editor", middlewares.RequirePermission(rbac.PermTagsEdit), controllers.DeleteTags)
+		contentRoutes.GET("/search", controllers.SearchContent)
+		contentRoutes.GET("/stats", controllers.FetchStats)
+		contentRoutes.GET("/reviews/due", controllers.FetchDueReviews)
+		contentRoutes.POST("/reviews/:slug", controllers.GradeReview)
+		contentRoutes.GET("/reviews/:slug/history", controllers.FetchReviewHistory)
 	}
 
 	// cron job routes
 	{
 		cronJobRoutes := r.Group("/api/cron")
-		cronJobRoutes.Use(middlewares.RequireAuth)
-		cronJobRoutes.POST("/questions/insert", controllers.InsertQuestions)
-		cronJobRoutes.POST("/submissions/insert", controllers.InsertSubmissions)
+		cronJobRoutes.POST("/questions/insert",
+			middlewares.RequireAPIKeyOrAuth(apikeys.ScopeCronQuestions), middlewares.RequireCSRF,
+			middlewares.RequirePermission(rbac.PermCronIngest), controllers.InsertQuestions)
+		cronJobRoutes.POST("/submissions/insert",
+			middlewares.RequireAPIKeyOrAuth(apikeys.ScopeCronSubmissions), middlewares.RequireCSRF,
+			middlewares.RequirePermission(rbac.PermCronIngest), controllers.InsertSubmissions)
+	}
+
+	// admin routes
+	{
+		adminRoutes := r.Group("/api/admin")
+		adminRoutes.Use(middlewares.RequireAuth, middlewares.RequireCSRF, middlewares.RequirePermission(rbac.PermAdmin))
+		adminRoutes.PATCH("/users/:username/role", controllers.UpdateUserRole)
+		adminRoutes.POST("/users/:username/unlock", controllers.UnlockUser)
+		adminRoutes.GET("/audit", controllers.FetchAuthEvents)
+		adminRoutes.POST("/invites", controllers.CreateInvite)
+		adminRoutes.GET("/invites", controllers.FetchInvites)
+		adminRoutes.DELETE("/invites/:id", controllers.RevokeInvite)
+		adminRoutes.GET("/tags", controllers.FetchTags)
+		adminRoutes.PATCH("/tags/:id", controllers.UpdateTag)
+		adminRoutes.POST("/tags/:id/merge", controllers.MergeTag)
 	}
 
 	r.Run()
@@ -0,0 +1,68 @@
+package middlewares
+
+import (
+	"database/sql"
+	"net/http"
+	"reviser/internal/apikeys"
+	"reviser/internal/inits"
+	"reviser/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireAPIKeyOrAuth authenticates machine clients presenting an API key
+// with the given scope, and falls back to RequireAuth for everyone else
+func RequireAPIKeyOrAuth(scope string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		key := ctx.GetHeader(apikeys.HeaderName)
+		if key == "" {
+			RequireAuth(ctx)
+			return
+		}
+
+		var user models.User
+		var keyID uint
+		var scopes models.StringArray
+		row := inits.DB.QueryRow(
+			`SELECT k.key_id, k.scopes, u.name, u.username, u.role, u.timezone
+				FROM api_keys k
+				JOIN users u ON k.username = u.username
+				WHERE k.key_hash = $1 AND k.revoked_at IS NULL
+				LIMIT 1`,
+			apikeys.Hash(key))
+		err := row.Scan(&keyID, &scopes, &user.Name, &user.Username, &user.Role, &user.Timezone)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				ctx.JSON(401, gin.H{"error": "unauthorized", "message": "Invalid API key"})
+				ctx.AbortWithStatus(http.StatusUnauthorized)
+				return
+			}
+			ctx.JSON(500, gin.H{"error": "Failed to query API key", "details": err.Error()})
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		granted := false
+		for _, s := range scopes {
+			if s == scope {
+				granted = true
+				break
+			}
+		}
+		if !granted {
+			ctx.JSON(403, gin.H{"error": "forbidden", "message": "API key lacks scope " + scope})
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		if _, err := inits.DB.Exec("UPDATE api_keys SET last_used_at = now() WHERE key_id = $1", keyID); err != nil {
+			ctx.JSON(500, gin.H{"error": "Failed to update API key", "details": err.Error()})
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		ctx.Set("user", user)
+		ctx.Set("auth_method", AuthMethodAPIKey)
+		ctx.Next()
+	}
+}
@@ -2,72 +2,141 @@ package middlewares
 
 import (
 	"database/sql"
-	"fmt"
+	"log"
 	"net/http"
-	"os"
+	"reviser/internal/audit"
 	"reviser/internal/inits"
 	"reviser/internal/models"
+	"reviser/internal/ratelimit"
+	"reviser/internal/tokens"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
 )
 
-func RequireAuth(ctx *gin.Context) {
-	tokenString, err := ctx.Cookie("Authorization")
+// Ways a request can present its access token, stored in the context
+// under "auth_method" by RequireAuth
+const (
+	AuthMethodCookie = "cookie"
+	AuthMethodBearer = "bearer"
+	AuthMethodAPIKey = "api_key"
+)
 
+// At most invalidTokenAuditLimit invalid tokens are audited per client IP
+// and window, so unauthenticated clients cannot flood auth_events
+const (
+	invalidTokenAuditLimit  = 20
+	invalidTokenAuditWindow = time.Hour
+)
+
+// recordInvalidToken audits a rejected token unless the client IP already
+// used up its allowance
+func recordInvalidToken(ctx *gin.Context, username string, details string) {
+	wait, err := ratelimit.Hit(ctx, "token-invalid:ip:"+ctx.ClientIP(), invalidTokenAuditLimit, invalidTokenAuditWindow)
 	if err != nil {
-		ctx.JSON(401, gin.H{"error": "unauthorized", "message": "No token provided"})
-		ctx.AbortWithStatus(http.StatusUnauthorized)
+		log.Printf("Failed to count invalid tokens from %s: %v", ctx.ClientIP(), err)
 		return
 	}
+	if wait > 0 {
+		return
+	}
+	audit.Record(ctx, audit.EventTokenInvalid, username, details)
+}
 
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+// accessToken extracts the access token from the request. An Authorization
+// header takes precedence over the cookie, and a header that is present but
+// not a Bearer token is rejected rather than silently ignored
+func accessToken(ctx *gin.Context) (token string, method string, message string) {
+	if header := ctx.GetHeader("Authorization"); header != "" {
+		scheme, token, found := strings.Cut(header, " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+			return "", "", "Malformed Authorization header"
 		}
-		return []byte(os.Getenv("JWT_SECRET")), nil
-	})
-	if err != nil || !token.Valid {
-		ctx.JSON(401, gin.H{"error": "Invalid token"})
+		return strings.TrimSpace(token), AuthMethodBearer, ""
+	}
+
+	token, err := ctx.Cookie("Authorization")
+	if err != nil || token == "" {
+		return "", "", "No token provided"
+	}
+	return token, AuthMethodCookie, ""
+}
+
+// RequireAuth authenticates the request with a Bearer token from the
+// Authorization header or, failing that, the Authorization cookie
+func RequireAuth(ctx *gin.Context) {
+	tokenString, method, message := accessToken(ctx)
+
+	if tokenString == "" {
+		ctx.JSON(401, gin.H{"error": "unauthorized", "message": message})
 		ctx.AbortWithStatus(http.StatusUnauthorized)
 		return
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok {
-		if float64(time.Now().Unix()) > claims["exp"].(float64) {
+	claims, err := tokens.ParseAccessToken(tokenString)
+	if err != nil {
+		// expiry is routine for short-lived tokens and is not audited
+		if ve, ok := err.(*jwt.ValidationError); ok && ve.Errors&jwt.ValidationErrorExpired != 0 {
 			ctx.JSON(401, gin.H{"error": "unauthorized", "message": "Token expired"})
 			ctx.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
+		recordInvalidToken(ctx, "", err.Error())
+		ctx.JSON(401, gin.H{"error": "Invalid token"})
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
 
-		// Validate the user using the username from the claims
-		username, ok := claims["username"].(string)
-		if !ok {
-			ctx.JSON(401, gin.H{"error": "unauthorized", "message": "Invalid token payload"})
-			ctx.AbortWithStatus(http.StatusUnauthorized)
-			return
-		}
+	exp, ok := claims["exp"].(float64)
+	if !ok || float64(time.Now().Unix()) > exp {
+		ctx.JSON(401, gin.H{"error": "unauthorized", "message": "Token expired"})
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
 
-		var user models.User
-		row := inits.DB.QueryRow("SELECT Name, Username FROM users WHERE username = $1 LIMIT 1", username)
-		err := row.Scan(&user.Name, &user.Username)
-		if err != nil {
-			if err == sql.ErrNoRows {
-				ctx.JSON(401, gin.H{"error": "unauthorized", "message": "User not found"})
-				ctx.AbortWithStatus(http.StatusUnauthorized)
-				return
-			}
-			ctx.JSON(500, gin.H{"error": "Failed to query user", "details": err.Error()})
-			ctx.AbortWithStatus(http.StatusInternalServerError)
+	// Validate the user and session using the claims
+	username, ok := claims["username"].(string)
+	if !ok {
+		recordInvalidToken(ctx, "", "Invalid token payload")
+		ctx.JSON(401, gin.H{"error": "unauthorized", "message": "Invalid token payload"})
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	sessionID, ok := claims["sid"].(string)
+	if !ok {
+		recordInvalidToken(ctx, username, "Invalid token payload")
+		ctx.JSON(401, gin.H{"error": "unauthorized", "message": "Invalid token payload"})
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	var user models.User
+	var csrfToken string
+	row := inits.DB.QueryRow(
+		`SELECT u.Name, u.Username, u.Role, u.timezone, s.csrf_token
+			FROM users u
+			JOIN sessions s ON s.username = u.username
+			WHERE u.username = $1 AND s.session_id = $2 AND s.revoked_at IS NULL
+			LIMIT 1`,
+		username, sessionID)
+	err = row.Scan(&user.Name, &user.Username, &user.Role, &user.Timezone, &csrfToken)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			recordInvalidToken(ctx, username, "Session revoked or user not found")
+			ctx.JSON(401, gin.H{"error": "unauthorized", "message": "Session revoked or user not found"})
+			ctx.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
-
-		ctx.Set("user", user)
-	} else {
-		ctx.JSON(401, gin.H{"error": "Invalid token claims"})
-		ctx.AbortWithStatus(http.StatusUnauthorized)
+		ctx.JSON(500, gin.H{"error": "Failed to query user", "details": err.Error()})
+		ctx.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
+
+	ctx.Set("user", user)
+	ctx.Set("session_id", sessionID)
+	ctx.Set("auth_method", method)
+	ctx.Set("csrf_token", csrfToken)
 	ctx.Next()
 }
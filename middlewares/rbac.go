@@ -0,0 +1,35 @@
+package middlewares
+
+import (
+	"net/http"
+	"reviser/internal/models"
+	"reviser/internal/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequirePermission rejects users whose role lacks permission.
+// It must run after the middleware that sets the user in the context
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		value, _ := ctx.Get("user")
+		user, ok := value.(models.User)
+		if !ok {
+			ctx.JSON(500, gin.H{"error": "User not found in context"})
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		if !rbac.Can(user.Role, permission) {
+			ctx.JSON(403, gin.H{
+				"error":      "forbidden",
+				"message":    "Insufficient permissions",
+				"permission": permission,
+				"role":       user.Role,
+			})
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		ctx.Next()
+	}
+}